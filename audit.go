@@ -0,0 +1,238 @@
+package runner
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altstory/go-config"
+	"github.com/altstory/go-log"
+)
+
+// 配置字段的变化类型。
+const (
+	ConfigChangeAdded    = "added"    // ConfigChangeAdded 表示新增了字段。
+	ConfigChangeRemoved  = "removed"  // ConfigChangeRemoved 表示删除了字段。
+	ConfigChangeModified = "modified" // ConfigChangeModified 表示字段的值发生了变化。
+)
+
+// ConfigChange 是配置中一个字段的变化，敏感字段的值会被替换成 "******"。
+type ConfigChange struct {
+	Key  string      `json:"key"`           // Key 是字段的完整路径，例如 log.log_level。
+	Type string      `json:"type"`          // Type 是变化类型，取值为 ConfigChangeAdded、ConfigChangeRemoved 或 ConfigChangeModified。
+	Old  interface{} `json:"old,omitempty"` // Old 是字段变化前的值。
+	New  interface{} `json:"new,omitempty"` // New 是字段变化后的值。
+}
+
+// ConfigChangeRecord 是一次配置变更的记录。
+type ConfigChangeRecord struct {
+	Time    time.Time       `json:"time"`    // Time 是配置生效的时间。
+	Files   []string        `json:"files"`   // Files 是生成新配置的所有配置文件，按加载顺序排列。
+	Changes []*ConfigChange `json:"changes"` // Changes 是所有发生变化的字段，按字段路径排序。
+}
+
+var configHistory struct {
+	mu      sync.Mutex
+	records []*ConfigChangeRecord
+}
+
+// ConfigChanges 返回最近的配置变更记录，越靠后的记录越新。
+//
+// 服务启动时额外配置文件对主配置文件的修改，以及运行过程中每次重新加载配置带来的修改都会被记录下来，
+// 最多保留 [runner] 中 config_history 条记录，默认是 20 条。
+// 业务可以通过自己的管理接口输出这些记录，方便排查配置问题。
+func ConfigChanges() []*ConfigChangeRecord {
+	configHistory.mu.Lock()
+	defer configHistory.mu.Unlock()
+
+	records := make([]*ConfigChangeRecord, len(configHistory.records))
+	copy(records, configHistory.records)
+	return records
+}
+
+// recordConfigChanges 计算配置从 from 变成 to 之后的所有变化，输出到日志并记录到 configHistory 中。
+func recordConfigChanges(ctx context.Context, from, to *config.Config, files []*configFile) {
+	all := append(builtinConfigBindings(), configBindings...)
+	changes, err := diffConfig(from, to, secretConfigKeys(all))
+
+	if err != nil {
+		log.Warnf(ctx, "err=%v||go-runner: fail to diff config", err)
+		return
+	}
+
+	if len(changes) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(files))
+
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+
+	for _, change := range changes {
+		log.Infof(ctx, "key=%v||type=%v||old=%v||new=%v||go-runner: config is changed",
+			change.Key, change.Type, formatConfigChangeValue(change.Old), formatConfigChangeValue(change.New))
+	}
+
+	record := &ConfigChangeRecord{
+		Time:    time.Now(),
+		Files:   names,
+		Changes: changes,
+	}
+	limit := runnerFromContext(ctx).RunnerConfig.ConfigHistory
+
+	configHistory.mu.Lock()
+	defer configHistory.mu.Unlock()
+
+	configHistory.records = append(configHistory.records, record)
+
+	if len(configHistory.records) > limit {
+		configHistory.records = append([]*ConfigChangeRecord{}, configHistory.records[len(configHistory.records)-limit:]...)
+	}
+}
+
+// diffConfig 逐个字段对比 from 和 to，返回按字段路径排序的所有变化，secrets 中的字段和敏感字段的值会被隐藏。
+func diffConfig(from, to *config.Config, secrets map[string]bool) ([]*ConfigChange, error) {
+	oldValues, err := flattenConfig(from)
+
+	if err != nil {
+		return nil, err
+	}
+
+	newValues, err := flattenConfig(to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*ConfigChange
+
+	for key, old := range oldValues {
+		v, ok := newValues[key]
+
+		if !ok {
+			changes = append(changes, &ConfigChange{
+				Key:  key,
+				Type: ConfigChangeRemoved,
+				Old:  old,
+			})
+			continue
+		}
+
+		if !reflect.DeepEqual(old, v) {
+			changes = append(changes, &ConfigChange{
+				Key:  key,
+				Type: ConfigChangeModified,
+				Old:  old,
+				New:  v,
+			})
+		}
+	}
+
+	for key, v := range newValues {
+		if _, ok := oldValues[key]; !ok {
+			changes = append(changes, &ConfigChange{
+				Key:  key,
+				Type: ConfigChangeAdded,
+				New:  v,
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	for _, change := range changes {
+		if isSecretConfigPath(secrets, change.Key) {
+			if change.Old != nil {
+				change.Old = redactedValue
+			}
+
+			if change.New != nil {
+				change.New = redactedValue
+			}
+
+			continue
+		}
+
+		redactConfigChangeValue(secrets, change.Key, change.Old)
+		redactConfigChangeValue(secrets, change.Key, change.New)
+	}
+
+	return changes, nil
+}
+
+// flattenConfig 将 c 中的所有字段展开成完整路径到字段值的映射，数组会被当做一个完整的值。
+func flattenConfig(c *config.Config) (map[string]interface{}, error) {
+	var raw interface{}
+
+	if err := c.Unmarshal("", &raw); err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+
+	if m, ok := normalizeConfig(reflect.ValueOf(raw)).(map[string]interface{}); ok {
+		flattenConfigTable(values, "", m)
+	}
+
+	return values, nil
+}
+
+func flattenConfigTable(values map[string]interface{}, path string, m map[string]interface{}) {
+	for k, v := range m {
+		key := joinConfigPath(path, k)
+
+		if table, ok := v.(map[string]interface{}); ok {
+			flattenConfigTable(values, key, table)
+			continue
+		}
+
+		values[key] = v
+	}
+}
+
+// isSecretConfigPath 判断 key 或者 key 的任意一级父字段是否是敏感字段。
+func isSecretConfigPath(secrets map[string]bool, key string) bool {
+	for {
+		if isSecretConfigKey(secrets, key) {
+			return true
+		}
+
+		idx := strings.LastIndex(key, ".")
+
+		if idx < 0 {
+			return false
+		}
+
+		key = key[:idx]
+	}
+}
+
+// redactConfigChangeValue 隐藏数组中所有表格里的敏感字段。
+func redactConfigChangeValue(secrets map[string]bool, key string, v interface{}) {
+	list, ok := v.([]interface{})
+
+	if !ok {
+		return
+	}
+
+	for _, elem := range list {
+		if table, ok := elem.(map[string]interface{}); ok {
+			redactConfig(secrets, key, table)
+		}
+	}
+}
+
+func formatConfigChangeValue(v interface{}) string {
+	if v == nil {
+		return "<nil>"
+	}
+
+	return formatTOMLValue(v)
+}
@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/altstory/go-config"
+	"github.com/huandu/go-assert"
+)
+
+type testAuditConfig struct {
+	Key string `config:"key" secret:"true"`
+}
+
+func TestDiffConfig(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	oldPath := filepath.Join(dir, "old.conf")
+	newPath := filepath.Join(dir, "new.conf")
+	writeTestFile(a, oldPath, `[server]
+addr = ":8080"
+debug = true
+password = "123"
+key = "abc"
+
+[[server.backends]]
+addr = "10.0.0.1:80"
+token = "t1"
+`)
+	writeTestFile(a, newPath, `[server]
+addr = ":9090"
+password = "456"
+key = "def"
+timeout = "1s"
+
+[[server.backends]]
+addr = "10.0.0.2:80"
+token = "t2"
+
+[secrets]
+foo = "bar"
+`)
+
+	from, err := config.LoadFile(oldPath)
+	a.NilError(err)
+	to, err := config.LoadFile(newPath)
+	a.NilError(err)
+
+	changes, err := diffConfig(from, to, map[string]bool{"server.key": true, "secrets": true})
+	a.NilError(err)
+	a.Equal(changes, []*ConfigChange{
+		{Key: "secrets.foo", Type: ConfigChangeAdded, New: redactedValue},
+		{Key: "server.addr", Type: ConfigChangeModified, Old: ":8080", New: ":9090"},
+		{
+			Key:  "server.backends",
+			Type: ConfigChangeModified,
+			Old:  []interface{}{map[string]interface{}{"addr": "10.0.0.1:80", "token": redactedValue}},
+			New:  []interface{}{map[string]interface{}{"addr": "10.0.0.2:80", "token": redactedValue}},
+		},
+		{Key: "server.debug", Type: ConfigChangeRemoved, Old: true},
+		{Key: "server.key", Type: ConfigChangeModified, Old: redactedValue, New: redactedValue},
+		{Key: "server.password", Type: ConfigChangeModified, Old: redactedValue, New: redactedValue},
+		{Key: "server.timeout", Type: ConfigChangeAdded, New: "1s"},
+	})
+
+	changes, err = diffConfig(to, to, nil)
+	a.NilError(err)
+	a.Equal(len(changes), 0)
+}
+
+func TestRecordConfigChanges(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	extPath := filepath.Join(dir, "conf", "conf.d", "ext.conf")
+	writeTestFile(a, confPath, `[log]
+log_path = "`+filepath.Join(dir, "log", "test.log")+`"
+
+[runner]
+config_history = 2
+
+[foo]
+bar = 1
+key = "abc"
+`)
+	writeTestFile(a, extPath, "[foo]\nbar = 2\nkey = \"def\"\n")
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		configHandlers = nil
+		configBindings = nil
+		configHistory.records = nil
+	}()
+
+	configHandlers = nil
+	configBindings = nil
+	configHistory.records = nil
+
+	var foo *testAuditConfig
+	LoadConfig("foo", &foo)
+	a.Equal(run(), ExitCodeOK)
+
+	// 启动时会记录 conf.d 中的文件对主配置文件的修改。
+	records := ConfigChanges()
+	a.Equal(len(records), 1)
+	a.Equal(records[0].Files, []string{confPath, extPath})
+	a.Equal(records[0].Changes, []*ConfigChange{
+		{Key: "foo.bar", Type: ConfigChangeModified, Old: int64(1), New: int64(2)},
+		{Key: "foo.key", Type: ConfigChangeModified, Old: redactedValue, New: redactedValue},
+	})
+
+	runner := &runnerContext{}
+	ctx := context.WithValue(context.Background(), keyRunnerContext, runner)
+	c, _, err := loadConfigFiles(ctx, confPath)
+	a.NilError(err)
+	runner.Config = c
+	runner.ConfigPath = confPath
+	a.Equal(loadRunnerConfig(ctx), ExitCodeOK)
+
+	for i := 3; i <= 5; i++ {
+		a.NilError(reloadConfig(ctx, []*ConfigLayer{{Name: "reload", Data: []byte(fmt.Sprintf("[foo]\nbar = %v\n", i))}}))
+	}
+
+	// 没有变化的配置不会产生记录。
+	a.NilError(reloadConfig(ctx, []*ConfigLayer{{Name: "reload", Data: []byte("[foo]\nbar = 5\n")}}))
+
+	// 只保留最近的 config_history 条记录。
+	records = ConfigChanges()
+	a.Equal(len(records), 2)
+	a.Equal(records[0].Changes, []*ConfigChange{
+		{Key: "foo.bar", Type: ConfigChangeModified, Old: int64(3), New: int64(4)},
+	})
+	a.Equal(records[1].Files, []string{confPath, extPath, "reload"})
+	a.Equal(records[1].Changes, []*ConfigChange{
+		{Key: "foo.bar", Type: ConfigChangeModified, Old: int64(4), New: int64(5)},
+	})
+}
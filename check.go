@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// registeredHandler 记录了一个已经注册的 handler，用于在不执行 handler 的情况下检查 handler 是否合法。
+type registeredHandler struct {
+	Caller string       // Caller 是注册 handler 的调用方。
+	Spec   *handlerSpec // Spec 是 handler 的解析结果。
+	Err    error        // Err 是 handler 不合法的原因。
+}
+
+var registeredHandlers []*registeredHandler
+
+// handlerError 是一个不合法的 handler。
+type handlerError struct {
+	Caller string
+	Err    error
+}
+
+func (err *handlerError) Error() string {
+	if err.Caller == "" {
+		return err.Err.Error()
+	}
+
+	return fmt.Sprintf("%v: %v", err.Caller, err.Err)
+}
+
+// handlerErrors 是所有不合法的 handler。
+type handlerErrors []*handlerError
+
+func (errs handlerErrors) Error() string {
+	lines := make([]string, 0, len(errs)+1)
+	lines = append(lines, "go-runner: invalid handlers:")
+
+	for _, err := range errs {
+		lines = append(lines, "    "+err.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Validate 检查所有通过 AddClient、AddServer、OnStart 等函数注册的 handler 是否合法，不会执行任何 handler。
+//
+// 检查的内容包括 handler 的函数签名、handler 依赖的类型是否由某个 client 提供、client 之间是否存在循环依赖
+// 以及 OnStart 和 OnExit 的顺序约束是否存在循环，
+// 返回的错误会列出所有不合法的 handler 以及注册它们的调用方。
+// 由于 handler 一般在 init 中注册，业务可以在单元测试中调用这个函数，尽早发现不合法的 handler：
+//
+//     func TestHandlers(t *testing.T) {
+//         if err := runner.Validate(); err != nil {
+//             t.Fatal(err)
+//         }
+//     }
+func Validate() error {
+	var errs handlerErrors
+
+	for _, h := range registeredHandlers {
+		if h.Err != nil {
+			errs = append(errs, &handlerError{
+				Caller: h.Caller,
+				Err:    h.Err,
+			})
+			continue
+		}
+
+		for _, t := range h.Spec.Requires() {
+			if !isProvidedType(t) {
+				errs = append(errs, &handlerError{
+					Caller: h.Caller,
+					Err:    fmt.Errorf("go-runner: dependency %v is not provided by any client", t),
+				})
+			}
+		}
+	}
+
+	if _, err := sortClients(clientHandlers); err != nil {
+		errs = append(errs, &handlerError{
+			Err: err,
+		})
+	}
+
+	for _, hooks := range [][]*hook{onStartHandlers, onPreStopHandlers} {
+		if _, err := sortHooks(hooks); err != nil {
+			errs = append(errs, &handlerError{
+				Err: err,
+			})
+		}
+	}
+
+	if _, err := sortExitHooks(onStartHandlers, onExitHandlers); err != nil {
+		errs = append(errs, &handlerError{
+			Err: err,
+		})
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
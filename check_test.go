@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestValidate(t *testing.T) {
+	a := assert.New(t)
+
+	defer resetTestInjection()
+	resetTestInjection()
+
+	AddClient("foo", func(ctx context.Context, c *fooConfig) (*testDB, error) { return nil, nil })
+	AddServer("foo", func(ctx context.Context, c *fooConfig, db *testDB) {})
+	OnStartHandler(func(ctx context.Context, db *testDB) error { return nil })
+	a.NilError(Validate())
+
+	AddServer("", 1)
+	OnStartHandler(func(ctx context.Context, cache *testCache) {})
+	AddClient("", func(ctx context.Context, store testStore) *testCache { return nil })
+	AddClient("", func(ctx context.Context, cache *testCache) testStore { return nil })
+
+	err := Validate()
+	a.NonNilError(err)
+
+	errs := err.(handlerErrors)
+	a.Equal(len(errs), 2)
+	a.Assert(strings.HasPrefix(errs[0].Caller, "check_test.go:"))
+	a.Equal(errs[0].Err.Error(), "go-runner: handler should be a func")
+	a.Assert(strings.Contains(errs[1].Error(), "circular dependency among clients"))
+	a.Assert(strings.HasPrefix(err.Error(), "go-runner: invalid handlers:\n    check_test.go:"))
+
+	resetTestInjection()
+	AddServer("", func(ctx context.Context, store testStore) {})
+	errs = Validate().(handlerErrors)
+	a.Equal(len(errs), 1)
+	a.Equal(errs[0].Err.Error(), "go-runner: dependency runner.testStore is not provided by any client")
+}
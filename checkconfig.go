@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// checkConfig 加载配置文件并检查所有已注册的配置，但不会启动任何 client 和 server。
+//
+// 检查过程与启动服务时完全一样：所有 LoadConfig、AddClient、AddServer 注册的配置都会被反序列化、
+// 设置默认值并校验，但是不会调用任何 handler。
+// 检查结果会按 section 逐个输出到 w，只要有一个 section 检查失败就返回 ExitCodeInvalidConfig。
+func checkConfig(w io.Writer) int {
+	runner := &runnerContext{}
+	ctx := context.WithValue(context.Background(), keyRunnerContext, runner)
+	c, _, err := loadConfigFiles(ctx, *flagConfig)
+
+	if err != nil {
+		fmt.Fprintf(w, "FAIL\tconfig=%v\n\t%v\n", *flagConfig, err)
+		return ExitCodeInvalidConfig
+	}
+
+	runner.Config = c
+	code := ExitCodeOK
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	for _, binding := range append(builtinConfigBindings(), configBindings...) {
+		err := binding.Check(c)
+		section := binding.Section
+
+		if section == "" {
+			section = "<root>"
+		}
+
+		if err == nil {
+			fmt.Fprintf(tw, "PASS\t[%v]\t%v\n", section, binding.Caller)
+			continue
+		}
+
+		code = ExitCodeInvalidConfig
+		fmt.Fprintf(tw, "FAIL\t[%v]\t%v\n", section, binding.Caller)
+
+		if errs, ok := err.(validationErrors); ok {
+			for _, e := range errs {
+				fmt.Fprintf(tw, "\t\t%v\n", e)
+			}
+		} else {
+			fmt.Fprintf(tw, "\t\t%v\n", err)
+		}
+	}
+
+	if loadRunnerConfig(ctx) != ExitCodeOK || !runner.RunnerConfig.StrictConfig {
+		return code
+	}
+
+	unknown, err := unknownConfigKeys(c, configBindings)
+
+	if err != nil {
+		code = ExitCodeInvalidConfig
+		fmt.Fprintf(tw, "FAIL\t<strict>\t%v\n", err)
+		return code
+	}
+
+	if len(unknown) == 0 {
+		fmt.Fprintf(tw, "PASS\t<strict>\t\n")
+		return code
+	}
+
+	code = ExitCodeInvalidConfig
+	fmt.Fprintf(tw, "FAIL\t<strict>\t%v unknown key(s)\n", len(unknown))
+
+	for _, key := range unknown {
+		fmt.Fprintf(tw, "\t\t%v\n", key)
+	}
+
+	return code
+}
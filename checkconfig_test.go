@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestCheckConfig(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "service.conf")
+	writeTestFile(a, confPath, `[server]
+addr = ":8080"
+workers = 4
+timeout = "1s"
+
+[client]
+workers = 0
+unknown_key = 1
+`)
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		*flagStrictConfig = false
+		configHandlers = nil
+		configBindings = nil
+		clientHandlers = nil
+		serverHandlers = nil
+	}()
+
+	called := false
+	configHandlers = nil
+	configBindings = nil
+	clientHandlers = nil
+	serverHandlers = nil
+	AddServer("server", func(ctx context.Context, c *testValidateConfig) {
+		called = true
+	})
+	buf := &bytes.Buffer{}
+	a.Equal(checkConfig(buf), ExitCodeOK)
+	a.Assert(!called)
+	a.Assert(strings.Contains(buf.String(), "PASS  [server]"))
+
+	AddClient("client", func(ctx context.Context, c *testValidateConfig) {
+		called = true
+	})
+	buf.Reset()
+	a.Equal(checkConfig(buf), ExitCodeInvalidConfig)
+	a.Assert(!called)
+	a.Assert(strings.Contains(buf.String(), "FAIL  [client]"))
+	a.Assert(strings.Contains(buf.String(), "client.addr: value is required"))
+	a.Assert(strings.Contains(buf.String(), "client.workers: value 0 is less than 1"))
+	a.Assert(!strings.Contains(buf.String(), "<strict>"))
+
+	*flagStrictConfig = true
+	buf.Reset()
+	a.Equal(checkConfig(buf), ExitCodeInvalidConfig)
+	a.Assert(strings.Contains(buf.String(), "client.unknown_key"))
+
+	// 配置文件无法加载时，输出具体的错误。
+	*flagConfig = filepath.Join(dir, "not_exist.conf")
+	buf.Reset()
+	a.Equal(checkConfig(buf), ExitCodeInvalidConfig)
+	a.Assert(strings.Contains(buf.String(), "FAIL\tconfig="+*flagConfig))
+	a.Assert(strings.Contains(buf.String(), "go-runner: fail to parse config file "+*flagConfig+": "))
+}
@@ -2,22 +2,67 @@ package runner
 
 import (
 	"context"
+
+	"github.com/altstory/go-log"
 )
 
-var clientHandlers handlers
+// clientHandler 是通过 AddClient 注册的 client 工厂。
+type clientHandler struct {
+	Handler handler
+	Spec    *handlerSpec // Spec 是 handler 的解析结果，如果 handler 不合法则为 nil。
+	Caller  string
+}
+
+var clientHandlers []*clientHandler
 
 // AddClient 注册一个自注册的 client 工厂。
+//
+// handler 可以返回一个值，例如 func(ctx context.Context, config *Config) (*redis.Client, error)，
+// 这个值会按照类型注入到其他 client、OnStartHandler 和 server 的 handler 参数中。
+// 每个类型只能由一个 client 提供，client 会按照依赖关系依次初始化。
 func AddClient(section string, handler Handler) {
 	const skip = 1
-	h, err := parseHandler(section, handler)
+	caller := findCaller(skip)
+	spec, err := registerHandlerSpec(skip, []string{section}, handler, true)
+
+	if err == nil && spec.Provides != nil {
+		err = registerProvider(spec.Provides, caller)
+	}
+
+	info := &HandlerInfo{
+		Phase:   PhaseClient,
+		Section: section,
+		Caller:  caller,
+	}
 
 	if err != nil {
-		h = makeErrorHandler(skip, err)
+		clientHandlers = append(clientHandlers, &clientHandler{
+			Handler: measure(info, intercept(info, makeErrorHandler(skip, err))),
+			Caller:  caller,
+		})
+		return
 	}
 
-	clientHandlers = append(clientHandlers, h)
+	clientHandlers = append(clientHandlers, &clientHandler{
+		Handler: measure(info, intercept(info, spec.Handler())),
+		Spec:    spec,
+		Caller:  caller,
+	})
 }
 
 func runClients(ctx context.Context) int {
-	return clientHandlers.Call(ctx)
+	clients, err := sortClients(clientHandlers)
+
+	if err != nil {
+		log.Errorf(ctx, "err=%v||go-runner: invalid client dependencies", err)
+		return ExitCodeInvalidHandler
+	}
+
+	for _, c := range clients {
+		if code := c.Handler.Call(ctx); code != ExitCodeOK {
+			return code
+		}
+	}
+
+	return ExitCodeOK
 }
@@ -2,11 +2,15 @@ package runner
 
 import (
 	"context"
+	"reflect"
 
 	"github.com/altstory/go-config"
 	"github.com/altstory/go-log"
 )
 
+// configTagName 是 go-config 解析 struct 时使用的 field tag。
+const configTagName = "config"
+
 var configHandlers handlers
 
 // LoadConfig 将 v 注册到启动逻辑里面，一旦配置文件读取之后，会从指定的 secion 给 v 赋值。
@@ -26,15 +30,30 @@ var configHandlers handlers
 //         runner.LoadConfig("foo", &Foo)
 //     }
 func LoadConfig(section string, v interface{}) {
-	LoadConfigFile("", section, v)
+	const skip = 1
+	loadConfigFile(skip, "", section, v)
 }
 
 // LoadConfigFile 将 v 注册到启动逻辑里面，一旦配置文件读取之后，会从指定的 secion 给 v 赋值。
 // 跟 LoadConfig 不一样的是，通过指定 path，可以指定一个跟默认配置文件不一样的配置文件。
 func LoadConfigFile(path string, section string, v interface{}) {
-	configHandlers = append(configHandlers, func(ctx context.Context) int {
+	const skip = 1
+	loadConfigFile(skip, path, section, v)
+}
+
+func loadConfigFile(skip int, path string, section string, v interface{}) {
+	if t := reflect.TypeOf(v); t != nil && t.Kind() == reflect.Ptr {
+		bindConfig(skip+1, path, section, t.Elem())
+	}
+
+	info := &HandlerInfo{
+		Phase:   PhaseConfig,
+		Section: section,
+		Caller:  findCaller(skip + 1),
+	}
+	configHandlers = append(configHandlers, measure(info, func(ctx context.Context) int {
 		runner := runnerFromContext(ctx)
-		c := runner.Config
+		c := runner.CurrentConfig()
 
 		if path != "" {
 			conf, err := config.LoadFile(path)
@@ -47,7 +66,7 @@ func LoadConfigFile(path string, section string, v interface{}) {
 			c = conf
 		}
 
-		err := c.Unmarshal(section, v)
+		err := unmarshalConfig(c, section, v)
 
 		if err != nil {
 			log.Errorf(ctx, "err=%v||go-runner: fail to read config", err)
@@ -55,9 +74,176 @@ func LoadConfigFile(path string, section string, v interface{}) {
 		}
 
 		return ExitCodeOK
-	})
+	}))
 }
 
 func runConfigHandlers(ctx context.Context) int {
 	return configHandlers.Call(ctx)
 }
+
+// unmarshalConfig 从 c 中读取 section 并反序列化到 v，为 v 设置默认值并解析所有密钥引用，
+// 最后校验 v 的值是否合法。
+func unmarshalConfig(c *config.Config, section string, v interface{}) error {
+	if err := decodeConfig(c, section, v); err != nil {
+		return err
+	}
+
+	return verifyConfig(section, v)
+}
+
+// decodeConfig 从 c 中读取 section 并反序列化到 v，为 v 设置默认值，不会解析密钥引用和校验配置。
+func decodeConfig(c *config.Config, section string, v interface{}) error {
+	if err := c.Unmarshal(section, v); err != nil {
+		return err
+	}
+
+	// 读取原始数据，用来判断哪些字段在配置中不存在。
+	var raw interface{}
+
+	if err := c.Unmarshal(section, &raw); err != nil {
+		return err
+	}
+
+	if raw == nil {
+		return applyMissingDefaults(section, reflect.ValueOf(v))
+	}
+
+	return applyDefaults(section, reflect.ValueOf(v), reflect.ValueOf(raw))
+}
+
+// verifyConfig 解析 v 中所有的密钥引用并校验 v 的值是否合法，v 必须已经通过 decodeConfig 反序列化。
+func verifyConfig(section string, v interface{}) error {
+	if err := resolveSecrets(section, v); err != nil {
+		return err
+	}
+
+	return validateConfig(section, v)
+}
+
+// configBinding 记录了一个配置 section 与配置类型之间的绑定关系。
+type configBinding struct {
+	Path    string       // Path 是配置文件路径，为空表示使用默认配置文件。
+	Section string       // Section 是配置在文件里的位置。
+	Type    reflect.Type // Type 是反序列化时需要创建的值类型。
+	Caller  string       // Caller 是注册这个配置的调用方。
+	Handler bool         // Handler 为 true 表示这个配置是 handler 的参数，如果参数类型由 client 提供，这个绑定会被移除。
+}
+
+var configBindings []*configBinding
+
+// bindConfig 记录 section 需要被反序列化成 t 类型的值。
+func bindConfig(skip int, path, section string, t reflect.Type) {
+	configBindings = append(configBindings, &configBinding{
+		Path:    path,
+		Section: section,
+		Type:    t,
+		Caller:  findCaller(skip + 1),
+	})
+}
+
+// bindHandlerConfig 记录 handler 的配置参数需要从 section 反序列化成 t 类型的值。
+func bindHandlerConfig(skip int, section string, t reflect.Type) {
+	configBindings = append(configBindings, &configBinding{
+		Section: section,
+		Type:    t,
+		Caller:  findCaller(skip + 1),
+		Handler: true,
+	})
+}
+
+// unbindHandlerConfig 移除所有类型为 t 的 handler 参数绑定。
+func unbindHandlerConfig(t reflect.Type) {
+	bindings := configBindings[:0]
+
+	for _, binding := range configBindings {
+		if binding.Handler && binding.Type == t {
+			continue
+		}
+
+		bindings = append(bindings, binding)
+	}
+
+	configBindings = bindings
+}
+
+// Load 反序列化 binding 对应的配置，返回的值是指向 binding.Type 的指针。
+func (binding *configBinding) Load(c *config.Config) (reflect.Value, error) {
+	v, err := binding.Decode(c)
+
+	if err != nil {
+		return v, err
+	}
+
+	err = verifyConfig(binding.Section, v.Interface())
+	return v, err
+}
+
+// Decode 反序列化 binding 对应的配置并设置默认值，不会解析密钥引用和校验配置。
+// 返回的值是指向 binding.Type 的指针。
+func (binding *configBinding) Decode(c *config.Config) (reflect.Value, error) {
+	if binding.Path != "" {
+		conf, err := config.LoadFile(binding.Path)
+
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		c = conf
+	}
+
+	v := reflect.New(binding.Type)
+	err := decodeConfig(c, binding.Section, v.Interface())
+	return v, err
+}
+
+// Check 反序列化并校验 binding 对应的配置，返回所有发现的错误。
+func (binding *configBinding) Check(c *config.Config) error {
+	_, err := binding.Load(c)
+	return err
+}
+
+// checkConfigs 检查 c 中所有已注册的配置，并将所有错误一次性输出到日志。
+// 服务启动时会在启动任何 client 之前调用这个函数，重新加载配置时也会先检查新配置是否合法。
+func checkConfigs(ctx context.Context, c *config.Config) int {
+	runner := runnerFromContext(ctx)
+	code := ExitCodeOK
+
+	for _, binding := range configBindings {
+		err := binding.Check(c)
+
+		if err == nil {
+			continue
+		}
+
+		code = ExitCodeInvalidConfig
+
+		if errs, ok := err.(validationErrors); ok {
+			for _, e := range errs {
+				log.Errorf(ctx, "section=%v||field=%v||err=%v||caller=%v||go-runner: invalid config", binding.Section, e.Path, e.Err, binding.Caller)
+			}
+
+			continue
+		}
+
+		log.Errorf(ctx, "section=%v||err=%v||caller=%v||go-runner: fail to read config", binding.Section, err, binding.Caller)
+	}
+
+	if runner.RunnerConfig.StrictConfig {
+		unknown, err := unknownConfigKeys(c, configBindings)
+
+		if err != nil {
+			log.Errorf(ctx, "err=%v||go-runner: fail to read config", err)
+			return ExitCodeInvalidConfig
+		}
+
+		for _, key := range unknown {
+			log.Errorf(ctx, "key=%v||go-runner: unknown config key in strict mode", key)
+		}
+
+		if len(unknown) > 0 {
+			code = ExitCodeInvalidConfig
+		}
+	}
+
+	return code
+}
@@ -0,0 +1,274 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTagName = "default"
+
+// defaultsSetter 是可以自己设置默认值的配置，runner 会在反序列化配置之后调用 SetDefaults 方法。
+type defaultsSetter interface {
+	SetDefaults()
+}
+
+// applyDefaults 为 v 设置默认值，raw 是 v 在配置文件中对应的原始数据。
+//
+// 对于配置中不存在的字段，如果字段上有 default tag，会使用 tag 的值作为默认值，例如：
+//
+//     type ServerConfig struct {
+//         Addr    string        `config:"addr" default:":8080"`
+//         Timeout time.Duration `config:"timeout" default:"3s"`
+//         Tags    []string      `config:"tags" default:"foo,bar"`
+//     }
+//
+// slice 类型的默认值用“,”分隔。
+// 设置完 tag 中的默认值之后，如果配置实现了 SetDefaults 方法，会继续调用这个方法，
+// 业务可以在这个方法里面为零值字段设置更复杂的默认值。
+//
+// v 中的 nil 指针不会设置任何默认值，配置中不存在的 section 由 applyMissingDefaults 处理。
+func applyDefaults(path string, v reflect.Value, raw reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	for raw.IsValid() && raw.Kind() == reflect.Interface {
+		raw = raw.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return applyStructDefaults(path, v, raw)
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			var elem reflect.Value
+
+			if raw.IsValid() && (raw.Kind() == reflect.Slice || raw.Kind() == reflect.Array) && i < raw.Len() {
+				elem = raw.Index(i)
+			}
+
+			if err := applyDefaults(fmt.Sprintf("%v[%v]", path, i), v.Index(i), elem); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+
+		elemType := v.Type().Elem()
+		iter := v.MapRange()
+
+		for iter.Next() {
+			key := iter.Key()
+			elem := reflect.New(elemType).Elem()
+			elem.Set(iter.Value())
+
+			if err := applyDefaults(joinConfigPath(path, key.String()), elem, rawField(raw, key.String())); err != nil {
+				return err
+			}
+
+			v.SetMapIndex(key, elem)
+		}
+	}
+
+	return nil
+}
+
+// applyMissingDefaults 为配置中不存在的 section 设置默认值，v 是指向 section 的指针。
+//
+// 如果 section 是 nil 指针，会为它分配一个零值并设置默认值，
+// 只有默认值改变了零值时才会使用这个新的值，没有任何默认值的 section 依然是 nil。
+func applyMissingDefaults(path string, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Ptr {
+		return applyDefaults(path, v, reflect.Value{})
+	}
+
+	if !v.CanSet() {
+		return nil
+	}
+
+	elem := reflect.New(v.Type().Elem())
+
+	if err := applyMissingDefaults(path, elem); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(elem.Elem().Interface(), reflect.Zero(elem.Elem().Type()).Interface()) {
+		return nil
+	}
+
+	v.Set(elem)
+	return nil
+}
+
+func applyStructDefaults(path string, v reflect.Value, raw reflect.Value) error {
+	t := v.Type()
+
+	if t == typeOfTime {
+		return nil
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, squash, skipped := parseConfigTag(field)
+
+		if skipped {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		if squash {
+			if err := applyDefaults(path, fv, raw); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		fieldPath := joinConfigPath(path, name)
+		fieldRaw := rawField(raw, name)
+
+		if !fieldRaw.IsValid() {
+			if def, ok := field.Tag.Lookup(defaultTagName); ok {
+				if err := setDefaultValue(fv, def); err != nil {
+					return fmt.Errorf("go-runner: fail to set default value of %v: %v", fieldPath, err)
+				}
+			}
+		}
+
+		if err := applyDefaults(fieldPath, fv, fieldRaw); err != nil {
+			return err
+		}
+	}
+
+	if v.CanAddr() {
+		if setter, ok := v.Addr().Interface().(defaultsSetter); ok {
+			setter.SetDefaults()
+		}
+	}
+
+	return nil
+}
+
+// rawField 返回 raw 中 key 对应的值，如果 raw 不是 map 或者 key 不存在则返回无效值。
+func rawField(raw reflect.Value, key string) reflect.Value {
+	if !raw.IsValid() || raw.Kind() != reflect.Map {
+		return reflect.Value{}
+	}
+
+	value := raw.MapIndex(reflect.ValueOf(key))
+
+	if !value.IsValid() || (value.Kind() == reflect.Interface && value.IsNil()) {
+		return reflect.Value{}
+	}
+
+	return value
+}
+
+// setDefaultValue 将字符串 def 解析成 v 的类型并赋值给 v。
+func setDefaultValue(v reflect.Value, def string) error {
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+
+		if err := setDefaultValue(ptr.Elem(), def); err != nil {
+			return err
+		}
+
+		v.Set(ptr)
+		return nil
+	}
+
+	if v.Type() == typeOfDuration {
+		d, err := time.ParseDuration(def)
+
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(def)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(def, 10, v.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		ui, err := strconv.ParseUint(def, 10, v.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(ui)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(def, v.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(f)
+
+	case reflect.Slice:
+		var parts []string
+
+		if def != "" {
+			parts = strings.Split(def, ",")
+		}
+
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+
+		for i, part := range parts {
+			if err := setDefaultValue(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+
+		v.Set(slice)
+
+	default:
+		return fmt.Errorf("default value is not supported by type %v", v.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+type testDefaultsBackend struct {
+	Addr   string `config:"addr" validate:"required"`
+	Weight int    `config:"weight" default:"10"`
+}
+
+type testDefaultsConfig struct {
+	Addr     string                `config:"addr" default:":8080"`
+	Debug    bool                  `config:"debug" default:"true"`
+	Timeout  time.Duration         `config:"timeout" default:"3s"`
+	Ratio    float64               `config:"ratio" default:"0.5"`
+	Tags     []string              `config:"tags" default:"foo, bar"`
+	Workers  int                   `config:"workers" validate:"min=1"`
+	Backends []testDefaultsBackend `config:"backends"`
+}
+
+func (c *testDefaultsConfig) SetDefaults() {
+	if c.Workers == 0 {
+		c.Workers = 4
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf(`[log]
+log_path = %q
+
+[server]
+debug = false
+timeout = "1s"
+
+[[server.backends]]
+addr = "10.0.0.1:80"
+
+[[server.backends]]
+addr = "10.0.0.2:80"
+weight = 20
+`, filepath.Join(dir, "log", "test.log")))
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		configHandlers = nil
+		configBindings = nil
+		clientHandlers = nil
+	}()
+
+	var loaded, notExist *testDefaultsConfig
+	var received *testDefaultsConfig
+	configHandlers = nil
+	configBindings = nil
+	clientHandlers = nil
+	LoadConfig("server", &loaded)
+	LoadConfig("not_exist", &notExist)
+	AddClient("server", func(ctx context.Context, c *testDefaultsConfig) {
+		received = c
+	})
+	a.Equal(run(), ExitCodeOK)
+
+	expected := &testDefaultsConfig{
+		Addr:    ":8080",
+		Debug:   false,
+		Timeout: time.Second,
+		Ratio:   0.5,
+		Tags:    []string{"foo", "bar"},
+		Workers: 4,
+		Backends: []testDefaultsBackend{
+			{Addr: "10.0.0.1:80", Weight: 10},
+			{Addr: "10.0.0.2:80", Weight: 20},
+		},
+	}
+	a.Equal(loaded, expected)
+	a.Equal(received, expected)
+
+	// section 不存在时，handler 拿到的也是设置了默认值的配置。
+	a.Equal(notExist, &testDefaultsConfig{
+		Addr:    ":8080",
+		Debug:   true,
+		Timeout: 3 * time.Second,
+		Ratio:   0.5,
+		Tags:    []string{"foo", "bar"},
+		Workers: 4,
+	})
+
+	// 没有任何默认值的 section 依然是 nil。
+	var optional *fooConfig
+	configHandlers = nil
+	configBindings = nil
+	clientHandlers = nil
+	LoadConfig("not_exist", &optional)
+	a.Equal(run(), ExitCodeOK)
+	a.Assert(optional == nil)
+}
+
+type testInvalidDefaultConfig struct {
+	Timeout time.Duration `config:"timeout" default:"abc"`
+}
+
+func TestInvalidDefaults(t *testing.T) {
+	a := assert.New(t)
+	c := &testInvalidDefaultConfig{}
+	a.NonNilError(applyDefaults("foo", reflect.ValueOf(c), reflect.ValueOf(map[string]interface{}{})))
+}
@@ -21,8 +21,40 @@ import (
 //     - func(ctx context.Context, config *Config)：      这里的 `Config` 是配置文件里面对应的数据结构，
 //                                                        Run 会自动解析配置文件并反序列化到 Config 里面去。
 //     - func(ctx context.Context, config *Config) error：与上面的形式类似，只是允许返回一个 error，框架会自动报错。
+//
+// 配置参数除了可以是指向结构的指针，也可以是结构、key 为 string 的 map 或者 slice，例如：
+//     - func(ctx context.Context, config Config)
+//     - func(ctx context.Context, config map[string]interface{})
+//     - func(ctx context.Context, backends []Backend)：读取 [[backends]] 这样的数组。
+// 如果配置中不存在这个 section，指向结构的指针会是 nil。
+//
+// 如果需要读取多个配置 section，可以在配置结构中声明一个带 config tag 的空白字段来指定这个结构所在的 section，
+// 这样的结构可以作为 handler 的任意参数，例如：
+//     - func(ctx context.Context, config *Config, tracing *TracingConfig) error
+//
+//     type TracingConfig struct {
+//         _ struct{} `config:"tracing"`
+//
+//         Addr string `config:"addr"`
+//     }
+//
+// 在上面的参数之后，handler 还可以声明任意多个由 client 提供的依赖，例如：
+//     - func(ctx context.Context, config *Config, client *redis.Client) error
+//
+// 通过 AddClient 注册的 handler 还可以返回一个值，这个值会作为依赖注入到其他 handler 中，例如：
+//     - func(ctx context.Context, config *Config) (*redis.Client, error)
 type Handler interface{}
 
+// isNilHandler 判断 h 是否是 nil，包括有类型的 nil 函数。
+func isNilHandler(h Handler) bool {
+	if h == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(h)
+	return v.Kind() == reflect.Func && v.IsNil()
+}
+
 type handler func(ctx context.Context) int
 type handlers []handler
 
@@ -31,6 +63,26 @@ var (
 	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
 )
 
+// handlerSpec 是通过反射解析 Handler 得到的函数信息。
+type handlerSpec struct {
+	Caller   string          // Caller 是注册 handler 的调用方。
+	Func     reflect.Value   // Func 是 handler 本身。
+	Params   []*handlerParam // Params 是除了 ctx 以外的所有参数。
+	Provides reflect.Type    // Provides 是 handler 返回的值类型，只有 client 可以返回值。
+}
+
+// handlerParam 是 handler 的一个参数，参数要么从配置中读取，要么由 client 提供。
+type handlerParam struct {
+	Type    reflect.Type // Type 是参数类型。
+	Section string       // Section 是配置参数在配置文件中的位置。
+	Config  bool         // Config 为 true 表示这个参数是配置，但是如果参数类型由某个 client 提供，它依然会被当做依赖注入。
+}
+
+// IsConfig 判断参数是否需要从配置中读取。
+func (param *handlerParam) IsConfig() bool {
+	return param.Config && !isProvidedType(param.Type)
+}
+
 // parseHandler 根据反射解析 h 并生成真正的 handler 函数。
 //
 // section 的值会影响 h 第二个参数在配置中的读取方式。
@@ -38,11 +90,25 @@ var (
 // 如果 section 不为空，则只会将配置文件中指定 section 反序列化到这个参数中，
 // 这会参数读取的更精确。
 func parseHandler(section string, h Handler) (handler, error) {
+	spec, err := parseHandlerSpec([]string{section}, h, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return spec.Handler(), nil
+}
+
+// parseHandlerSpec 解析 h 的参数和返回值。
+//
+// sections 依次对应 h 在 ctx 之后的参数。如果只有一个 section，那么 h 的第二个参数可以不是配置；
+// 如果有多个 section，那么 h 必须依次为每个 section 声明一个配置参数。
+// 如果 withValue 为 true，h 可以返回一个值作为依赖。
+func parseHandlerSpec(sections []string, h Handler, withValue bool) (*handlerSpec, error) {
 	if h == nil {
 		return nil, errors.New("go-runner: handler should not be nil")
 	}
 
-	var cfgType reflect.Type
 	fn := reflect.ValueOf(h)
 	fnType := fn.Type()
 
@@ -55,8 +121,6 @@ func parseHandler(section string, h Handler) (handler, error) {
 
 	if inNum == 0 {
 		return nil, errors.New("go-runner: handler must have at least 1 argument")
-	} else if inNum > 2 {
-		return nil, errors.New("go-runner: handler must have 1 or 2 arguments")
 	}
 
 	in0 := fnType.In(0)
@@ -65,14 +129,57 @@ func parseHandler(section string, h Handler) (handler, error) {
 		return nil, errors.New("go-runner: the type of first argument of handler must be context.Context")
 	}
 
-	if inNum == 2 {
-		in1 := fnType.In(1)
+	if len(sections) > 1 && inNum-1 < len(sections) {
+		return nil, fmt.Errorf("go-runner: handler must have a config argument for each of sections %v", sections)
+	}
+
+	spec := &handlerSpec{
+		Func: fn,
+	}
+
+	for i := 1; i < inNum; i++ {
+		in := fnType.In(i)
+		param := &handlerParam{
+			Type: in,
+		}
+
+		if idx := i - 1; idx < len(sections) {
+			if isConfigType(in) {
+				param.Section = sections[idx]
+				param.Config = true
+			} else if len(sections) > 1 {
+				return nil, errors.New("go-runner: the type of config argument in handler must be a struct, a pointer to struct, a map or a slice")
+			}
+		} else if section, ok := configSectionOf(in); ok {
+			param.Section = section
+			param.Config = true
+		}
+
+		spec.Params = append(spec.Params, param)
+	}
+
+	if withValue {
+		switch outNum {
+		case 0:
+		case 1:
+			if out := fnType.Out(0); !isErrorType(out) {
+				spec.Provides = out
+			}
+		case 2:
+			if isErrorType(fnType.Out(0)) {
+				return nil, errors.New("go-runner: the first return value of handler must not be error")
+			}
+
+			if !isErrorType(fnType.Out(1)) {
+				return nil, errors.New("go-runner: the second return type of handler must be error")
+			}
 
-		if in1.Kind() != reflect.Ptr || in1.Elem().Kind() != reflect.Struct {
-			return nil, errors.New("go-runner: the type of second argument in handler must be a pointer to struct")
+			spec.Provides = fnType.Out(0)
+		default:
+			return nil, errors.New("go-runner: too many values returned by handler")
 		}
 
-		cfgType = in1
+		return spec, nil
 	}
 
 	if outNum > 1 {
@@ -86,44 +193,160 @@ func parseHandler(section string, h Handler) (handler, error) {
 			return nil, errors.New("go-runner: the return type of handler must be an interface")
 		}
 
-		if !out.Implements(typeOfError) || !typeOfError.Implements(out) {
+		if !isErrorType(out) {
 			return nil, errors.New("go-runner: the return type of handler must be error")
 		}
 	}
 
+	return spec, nil
+}
+
+func isErrorType(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.Implements(typeOfError) && typeOfError.Implements(t)
+}
+
+// isConfigType 判断 t 是否可以作为配置参数，配置参数可以是结构、指向结构的指针、key 为 string 的 map 或者 slice。
+func isConfigType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice:
+		return true
+	case reflect.Ptr:
+		return t.Elem().Kind() == reflect.Struct
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String
+	}
+
+	return false
+}
+
+// configSectionOf 返回 t 通过空白字段的 config tag 声明的 section，t 必须是结构或者指向结构的指针。
+func configSectionOf(t reflect.Type) (section string, ok bool) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Name != "_" {
+			continue
+		}
+
+		if section, ok = field.Tag.Lookup(configTagName); ok {
+			return
+		}
+	}
+
+	return
+}
+
+// Requires 返回所有需要注入的参数类型。
+func (spec *handlerSpec) Requires() (types []reflect.Type) {
+	for _, param := range spec.Params {
+		if !param.IsConfig() {
+			types = append(types, param.Type)
+		}
+	}
+
+	return
+}
+
+// Handler 生成真正执行 spec.Func 的 handler 函数。
+func (spec *handlerSpec) Handler() handler {
 	return func(ctx context.Context) int {
-		args := make([]reflect.Value, 0, 2)
+		runner := runnerFromContext(ctx)
+		args := make([]reflect.Value, 0, 1+len(spec.Params))
 		args = append(args, reflect.ValueOf(ctx))
 
-		if cfgType != nil {
-			// 初始化业务配置。
-			arg := reflect.New(cfgType)
-			runner := runnerFromContext(ctx)
+		for _, param := range spec.Params {
+			if param.IsConfig() {
+				// 初始化业务配置。
+				arg := reflect.New(param.Type)
+
+				if err := unmarshalConfig(runner.CurrentConfig(), param.Section, arg.Interface()); err != nil {
+					log.Errorf(ctx, "err=%v||go-runner: fail to read config", err)
+					return ExitCodeInvalidConfig
+				}
+
+				args = append(args, arg.Elem())
+				continue
+			}
+
+			dep, ok := runner.Dependency(param.Type)
 
-			if err := runner.Config.Unmarshal(section, arg.Interface()); err != nil {
-				log.Errorf(ctx, "err=%v||go-runner: fail to read config", err)
-				return ExitCodeInvalidConfig
+			if !ok {
+				log.Errorf(ctx, "type=%v||caller=%v||go-runner: dependency is not provided by any client", param.Type, spec.Caller)
+				return ExitCodeInvalidHandler
 			}
 
-			args = append(args, arg.Elem())
+			args = append(args, dep)
 		}
 
-		returns := fn.Call(args)
+		returns := spec.Func.Call(args)
+
+		if len(returns) > 0 {
+			last := returns[len(returns)-1]
 
-		if len(returns) > 0 && returns[0].IsValid() {
-			if err, ok := returns[0].Interface().(error); ok && err != nil {
-				log.Errorf(ctx, "err=%v||go-runner: fail to call handler", err)
+			if isErrorType(last.Type()) && !last.IsNil() {
+				log.Errorf(ctx, "err=%v||caller=%v||go-runner: fail to call handler", last.Interface(), spec.Caller)
 				return ExitCodeHandlerError
 			}
 		}
 
+		if spec.Provides != nil {
+			runner.Provide(spec.Provides, returns[0])
+		}
+
 		return ExitCodeOK
-	}, nil
+	}
+}
+
+// registerHandler 解析 h 并记录 h 所需的配置，如果 h 不合法则返回一个专门报错的 handler。
+func registerHandler(skip int, sections []string, h Handler) handler {
+	spec, err := registerHandlerSpec(skip+1, sections, h, false)
+
+	if err != nil {
+		return makeErrorHandler(skip+1, err)
+	}
+
+	return spec.Handler()
+}
+
+// registerHandlerSpec 解析 h 并记录 h 所需的配置。
+func registerHandlerSpec(skip int, sections []string, h Handler, withValue bool) (*handlerSpec, error) {
+	spec, err := parseHandlerSpec(sections, h, withValue)
+
+	if err != nil {
+		return nil, err
+	}
+
+	spec.Caller = findCaller(skip + 1)
+	registeredHandlers = append(registeredHandlers, &registeredHandler{
+		Caller: spec.Caller,
+		Spec:   spec,
+	})
+
+	// 由 client 提供的类型不是配置，不需要绑定。
+	for _, param := range spec.Params {
+		if param.IsConfig() {
+			bindHandlerConfig(skip+1, param.Section, param.Type)
+		}
+	}
+
+	return spec, nil
 }
 
 // makeErrorHandler 构建一个专门返回错误的 handler，并输出出错的函数信息。
 func makeErrorHandler(skip int, err error) handler {
 	caller := findCaller(skip + 1)
+	registeredHandlers = append(registeredHandlers, &registeredHandler{
+		Caller: caller,
+		Err:    err,
+	})
 
 	return func(ctx context.Context) int {
 		log.Errorf(ctx, "caller=%v||err=%v||go-runner: invalid handler", caller, err)
@@ -147,10 +370,15 @@ func findCaller(skip int) string {
 	return caller
 }
 
-func (h handler) Call(ctx context.Context) (code int) {
+func (h handler) Call(ctx context.Context) int {
+	return h.CallWithInfo(ctx, nil)
+}
+
+// CallWithInfo 执行 h，如果发生 panic，会将 info 一起报告给 PanicHandler。
+func (h handler) CallWithInfo(ctx context.Context, info *HandlerInfo) (code int) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Errorf(ctx, "go-runner: caught a panic: %v\n%v", r, string(debug.Stack()))
+			reportPanic(ctx, info, r, debug.Stack())
 			code = ExitCodeHandlerError
 		}
 	}()
@@ -2,7 +2,10 @@ package runner
 
 import (
 	"context"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/huandu/go-assert"
@@ -16,9 +19,12 @@ func testValid2(ctx context.Context) error                           { return ni
 func testValid3(ctx context.Context, c *testHandlerConfig)           {}
 func testValid4(ctx context.Context, c *testHandlerConfig) error     { return nil }
 func testValid5(ctx context.Context, c *testHandlerConfig) testError { return nil }
+func testValid6(ctx context.Context, c testHandlerConfig)            {}
+func testValid7(ctx context.Context, c map[string]interface{})       {}
+func testValid8(ctx context.Context, c []*testHandlerConfig) error   { return nil }
 
 func TestParseValidHandler(t *testing.T) {
-	handlers := []Handler{testValid1, testValid2, testValid3, testValid4, testValid5}
+	handlers := []Handler{testValid1, testValid2, testValid3, testValid4, testValid5, testValid6, testValid7, testValid8}
 
 	for _, h := range handlers {
 		_, err := parseHandler("", h)
@@ -34,7 +40,7 @@ func testInvalid2(c testHandlerConfig)
 func testInvalid3(c *testHandlerConfig) (i int, err error)                      { return }
 func testInvalid4(c *testHandlerConfig) (i interface{})                         { return }
 func testInvalid5(c *testHandlerConfig) (s string)                              { return }
-func testInvalid6(ctx context.Context, c testHandlerConfig)                     {}
+func testInvalid6(c testHandlerConfig, ctx context.Context)                     {}
 func testInvalid7(ctx context.Context, c *testHandlerConfig) (i int, err error) { return }
 func testInvalid8(ctx context.Context, c *testHandlerConfig) (i interface{})    { return }
 func testInvalid9(ctx context.Context, c *testHandlerConfig) (s string)         { return }
@@ -88,3 +94,139 @@ func TestUnmarshalConfig(t *testing.T) {
 	a.Assert(all[2] == nil)
 	a.Assert(all[3] == nil)
 }
+
+type testServerConfig struct {
+	Addr  string `config:"addr"`
+	Debug bool   `config:"debug"`
+}
+
+type testFooSection struct {
+	_ struct{} `config:"foo"`
+
+	Bar int `config:"bar"`
+}
+
+func TestParseMultiSectionHandler(t *testing.T) {
+	a := assert.New(t)
+
+	spec, err := parseHandlerSpec([]string{"http.server", "foo"}, func(ctx context.Context, s *testServerConfig, f *fooConfig) {}, false)
+	a.NilError(err)
+	a.Equal(spec.Params, []*handlerParam{
+		{Type: reflect.TypeOf(&testServerConfig{}), Section: "http.server", Config: true},
+		{Type: reflect.TypeOf(&fooConfig{}), Section: "foo", Config: true},
+	})
+
+	spec, err = parseHandlerSpec([]string{"http.server"}, func(ctx context.Context, s *testServerConfig, f *testFooSection) {}, false)
+	a.NilError(err)
+	a.Equal(spec.Params[1], &handlerParam{Type: reflect.TypeOf(&testFooSection{}), Section: "foo", Config: true})
+
+	_, err = parseHandlerSpec([]string{"http.server", "foo"}, func(ctx context.Context, s *testServerConfig) {}, false)
+	a.NonNilError(err)
+
+	_, err = parseHandlerSpec([]string{"http.server", "foo"}, func(ctx context.Context, s *testServerConfig, f testError) {}, false)
+	a.NonNilError(err)
+}
+
+func TestMultiSectionHandler(t *testing.T) {
+	a := assert.New(t)
+
+	old, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(old)
+	a.NilError(os.Chdir("./internal/testdata"))
+
+	defer func() {
+		configBindings = nil
+		serverHandlers = nil
+		onStartHandlers = nil
+	}()
+
+	configBindings = nil
+	serverHandlers = nil
+	onStartHandlers = nil
+
+	var server *testServerConfig
+	var foo1 *fooConfig
+	var foo2 *testFooSection
+	AddServerSections([]string{"http.server", "foo"}, func(ctx context.Context, s *testServerConfig, f *fooConfig) {
+		server = s
+		foo1 = f
+	})
+	OnStartHandler(func(ctx context.Context, f *testFooSection) error {
+		foo2 = f
+		return nil
+	})
+	a.Equal(len(configBindings), 3)
+	a.Equal(run(), ExitCodeOK)
+
+	a.Equal(server, &testServerConfig{Addr: ":12345", Debug: true})
+	a.Equal(foo1.Bar, 123)
+	a.Equal(foo2.Bar, 123)
+}
+
+type testBackend struct {
+	Addr   string `config:"addr"`
+	Weight int    `config:"weight" default:"1"`
+}
+
+func TestValueConfigHandler(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "service.conf")
+	writeTestFile(a, confPath, `[log]
+log_path = "`+filepath.Join(dir, "log", "test.log")+`"
+
+[foo]
+bar = 123
+
+[[backends]]
+addr = "10.0.0.1:80"
+weight = 2
+
+[[backends]]
+addr = "10.0.0.2:80"
+`)
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		configBindings = nil
+		clientHandlers = nil
+		serverHandlers = nil
+	}()
+
+	configBindings = nil
+	clientHandlers = nil
+	serverHandlers = nil
+
+	var foo fooConfig
+	var m map[string]interface{}
+	var backends []testBackend
+	var missing fooConfig
+	AddClient("foo", func(ctx context.Context, c fooConfig) {
+		foo = c
+	})
+	AddClient("foo", func(ctx context.Context, c map[string]interface{}) {
+		m = c
+	})
+	AddServer("backends", func(ctx context.Context, c []testBackend) {
+		backends = c
+	})
+	AddServer("not_exist", func(ctx context.Context, c fooConfig) {
+		missing = c
+	})
+	a.Equal(run(), ExitCodeOK)
+
+	a.Equal(foo, fooConfig{Bar: 123})
+	a.Equal(m, map[string]interface{}{"bar": int64(123)})
+	a.Equal(backends, []testBackend{
+		{Addr: "10.0.0.1:80", Weight: 2},
+		{Addr: "10.0.0.2:80", Weight: 1},
+	})
+	a.Equal(missing, fooConfig{})
+}
@@ -0,0 +1,210 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HookOption 是 OnStart、OnPreStop 和 OnExit 的可选参数，用来控制函数的执行顺序。
+//
+// 选项描述的都是启动时的先后顺序，OnPreStop 和 OnExit 注册的函数会按照相反的顺序执行，就像 defer 一样。
+// 如果 OnExit 注册的函数与 OnStart 注册的函数使用了相同的 HookName，
+// 这些退出函数会按照同名启动函数实际启动顺序的相反顺序执行，不需要重复设置 HookBefore、HookAfter 等选项。
+type HookOption func(opts *hookOptions)
+
+type hookOptions struct {
+	Name     string
+	Priority int
+	Before   []string
+	After    []string
+	Timeout  time.Duration
+}
+
+// HookName 为函数设置一个名字，其他函数可以通过 HookBefore 和 HookAfter 引用这个名字。
+// 多个函数可以使用相同的名字。
+func HookName(name string) HookOption {
+	return func(opts *hookOptions) {
+		opts.Name = name
+	}
+}
+
+// HookPriority 设置函数的优先级，在满足 HookBefore 和 HookAfter 约束的前提下，优先级数值越小越先启动，默认是 0。
+// 优先级相同的函数按照注册顺序启动。
+func HookPriority(priority int) HookOption {
+	return func(opts *hookOptions) {
+		opts.Priority = priority
+	}
+}
+
+// HookBefore 要求函数在所有名字为 names 的函数之前启动，不存在的名字会被忽略。
+func HookBefore(names ...string) HookOption {
+	return func(opts *hookOptions) {
+		opts.Before = append(opts.Before, names...)
+	}
+}
+
+// HookAfter 要求函数在所有名字为 names 的函数之后启动，不存在的名字会被忽略。
+func HookAfter(names ...string) HookOption {
+	return func(opts *hookOptions) {
+		opts.After = append(opts.After, names...)
+	}
+}
+
+// HookTimeout 设置 OnStart 注册的函数的超时时间，会覆盖 [runner] 中 start_timeout 的设置，对 OnExit 无效。
+//
+// 超时之后，传给函数的 ctx 会被取消，服务会以 ExitCodeStartTimeout 退出。
+// 退出之前会等待函数返回，最长等待 [runner] 中 shutdown_timeout 设置的时间。
+func HookTimeout(timeout time.Duration) HookOption {
+	return func(opts *hookOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+// hook 是一个通过 OnStart 或者 OnExit 注册的函数。
+type hook struct {
+	hookOptions
+
+	Handler handler
+	Caller  string
+}
+
+func newHook(h handler, caller string, opts []HookOption) *hook {
+	hk := &hook{
+		Handler: h,
+		Caller:  caller,
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&hk.hookOptions)
+		}
+	}
+
+	return hk
+}
+
+// sortHooks 返回 hooks 的启动顺序。
+//
+// 排序时首先满足 HookBefore 和 HookAfter 约束，其次按照优先级从小到大排序，最后保持注册顺序。
+// 如果约束之间存在循环，返回错误。
+func sortHooks(hooks []*hook) ([]*hook, error) {
+	named := map[string][]int{}
+
+	for i, hk := range hooks {
+		if hk.Name != "" {
+			named[hk.Name] = append(named[hk.Name], i)
+		}
+	}
+
+	// deps[i] 是所有必须在 hooks[i] 之前启动的函数。
+	deps := make([]map[int]bool, len(hooks))
+
+	for i := range hooks {
+		deps[i] = map[int]bool{}
+	}
+
+	for i, hk := range hooks {
+		for _, name := range hk.After {
+			for _, j := range named[name] {
+				if j != i {
+					deps[i][j] = true
+				}
+			}
+		}
+
+		for _, name := range hk.Before {
+			for _, j := range named[name] {
+				if j != i {
+					deps[j][i] = true
+				}
+			}
+		}
+	}
+
+	sorted := make([]*hook, 0, len(hooks))
+	done := make([]bool, len(hooks))
+
+	for len(sorted) < len(hooks) {
+		next := -1
+
+		for i, hk := range hooks {
+			if done[i] || !allDone(deps[i], done) {
+				continue
+			}
+
+			if next < 0 || hk.Priority < hooks[next].Priority {
+				next = i
+			}
+		}
+
+		if next < 0 {
+			var callers []string
+
+			for i, hk := range hooks {
+				if !done[i] {
+					callers = append(callers, hk.Caller)
+				}
+			}
+
+			return nil, fmt.Errorf("go-runner: circular order constraints among [%v]", strings.Join(callers, ", "))
+		}
+
+		done[next] = true
+		sorted = append(sorted, hooks[next])
+	}
+
+	return sorted, nil
+}
+
+// sortExitHooks 返回 exits 的启动顺序。
+//
+// 与 starts 中某个函数同名的退出函数，会被要求排在所有启动顺序更早的同名退出函数之后，
+// 这样按照相反顺序执行时，退出顺序就与启动顺序相反。其他约束与 sortHooks 相同。
+func sortExitHooks(starts, exits []*hook) ([]*hook, error) {
+	sorted, err := sortHooks(starts)
+
+	if err != nil {
+		return sortHooks(exits)
+	}
+
+	// names 是启动函数的名字，按照实际启动顺序排列，同名函数以第一个启动的函数为准。
+	rank := map[string]int{}
+	var names []string
+
+	for _, hk := range sorted {
+		if _, ok := rank[hk.Name]; ok || hk.Name == "" {
+			continue
+		}
+
+		rank[hk.Name] = len(names)
+		names = append(names, hk.Name)
+	}
+
+	derived := make([]*hook, 0, len(exits))
+
+	for _, hk := range exits {
+		r, ok := rank[hk.Name]
+
+		if !ok || r == 0 {
+			derived = append(derived, hk)
+			continue
+		}
+
+		cp := *hk
+		cp.After = append(append([]string{}, hk.After...), names[:r]...)
+		derived = append(derived, &cp)
+	}
+
+	return sortHooks(derived)
+}
+
+func allDone(deps map[int]bool, done []bool) bool {
+	for i := range deps {
+		if !done[i] {
+			return false
+		}
+	}
+
+	return true
+}
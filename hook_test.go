@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestSortHooks(t *testing.T) {
+	a := assert.New(t)
+	names := func(hooks []*hook) (list []string) {
+		for _, hk := range hooks {
+			list = append(list, hk.Caller)
+		}
+
+		return
+	}
+
+	hooks := []*hook{
+		newHook(nil, "consumer", []HookOption{HookName("consumer"), HookAfter("cache")}),
+		newHook(nil, "cache", []HookOption{HookName("cache"), HookAfter("db", "not_exist")}),
+		newHook(nil, "late", []HookOption{HookPriority(10)}),
+		newHook(nil, "plain", nil),
+		newHook(nil, "db", []HookOption{HookName("db")}),
+		newHook(nil, "early", []HookOption{HookPriority(-1), HookBefore("db")}),
+	}
+	sorted, err := sortHooks(hooks)
+	a.NilError(err)
+	a.Equal(names(sorted), []string{"early", "plain", "db", "cache", "consumer", "late"})
+
+	_, err = sortHooks([]*hook{
+		newHook(nil, "a", []HookOption{HookName("a"), HookAfter("b")}),
+		newHook(nil, "b", []HookOption{HookName("b"), HookAfter("a")}),
+	})
+	a.NonNilError(err)
+}
+
+func TestSortExitHooks(t *testing.T) {
+	a := assert.New(t)
+	names := func(hooks []*hook) (list []string) {
+		for _, hk := range hooks {
+			list = append(list, hk.Caller)
+		}
+
+		return
+	}
+
+	starts := []*hook{
+		newHook(nil, "consumer", []HookOption{HookName("consumer"), HookAfter("cache")}),
+		newHook(nil, "cache", []HookOption{HookName("cache"), HookAfter("db")}),
+		newHook(nil, "db", []HookOption{HookName("db")}),
+	}
+
+	// 退出函数只设置了名字，启动顺序与同名启动函数的实际启动顺序一致。
+	exits := []*hook{
+		newHook(nil, "db", []HookOption{HookName("db")}),
+		newHook(nil, "plain", nil),
+		newHook(nil, "consumer", []HookOption{HookName("consumer")}),
+		newHook(nil, "cache", []HookOption{HookName("cache")}),
+		newHook(nil, "unknown", []HookOption{HookName("unknown")}),
+	}
+	sorted, err := sortExitHooks(starts, exits)
+	a.NilError(err)
+	a.Equal(names(sorted), []string{"db", "plain", "cache", "consumer", "unknown"})
+
+	// 退出函数的选项与启动顺序冲突时返回错误。
+	_, err = sortExitHooks(starts, []*hook{
+		newHook(nil, "cache", []HookOption{HookName("cache"), HookAfter("consumer")}),
+		newHook(nil, "consumer", []HookOption{HookName("consumer")}),
+	})
+	a.NonNilError(err)
+}
+
+func TestStartAndExitOrder(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer func() {
+		resetTestInjection()
+		onExitHandlers = nil
+	}()
+	resetTestInjection()
+	onExitHandlers = nil
+
+	var calls []string
+	register := func(name string, opts ...HookOption) {
+		OnStart(func(ctx context.Context) error {
+			calls = append(calls, "start:"+name)
+			return nil
+		}, opts...)
+		OnExit(func(ctx context.Context) {
+			calls = append(calls, "exit:"+name)
+		}, opts...)
+	}
+	register("consumer", HookName("consumer"), HookAfter("cache"))
+	register("cache", HookName("cache"))
+	register("first", HookPriority(-1))
+
+	// 退出函数只设置了名字，执行顺序与同名启动函数相反。
+	OnStart(func(ctx context.Context) error {
+		calls = append(calls, "start:writer")
+		return nil
+	}, HookName("writer"), HookBefore("cache"))
+	OnExit(func(ctx context.Context) {
+		calls = append(calls, "exit:writer")
+	}, HookName("writer"))
+
+	OnExit(func(ctx context.Context) {
+		calls = append(calls, "exit:last")
+	})
+
+	a.NilError(Validate())
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(calls, []string{
+		"start:first", "start:writer", "start:cache", "start:consumer",
+		"exit:last", "exit:consumer", "exit:cache", "exit:writer", "exit:first",
+	})
+
+	OnStart(func(ctx context.Context) error { return nil }, HookName("a"), HookAfter("a", "b"))
+	OnStart(func(ctx context.Context) error { return nil }, HookName("b"), HookAfter("a"))
+	a.NonNilError(Validate())
+	a.Equal(run(), ExitCodeInvalidHandler)
+}
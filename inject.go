@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// providers 记录每个类型由哪个 client 提供。
+var providers = map[reflect.Type]string{}
+
+// registerProvider 记录 t 类型的值由 caller 注册的 client 提供。
+func registerProvider(t reflect.Type, caller string) error {
+	if existing, ok := providers[t]; ok {
+		return fmt.Errorf("go-runner: type %v is already provided by %v", t, existing)
+	}
+
+	providers[t] = caller
+
+	// 提供的类型可能已经被当做配置参数绑定过了，需要移除这些绑定。
+	unbindHandlerConfig(t)
+	return nil
+}
+
+func isProvidedType(t reflect.Type) bool {
+	_, ok := providers[t]
+	return ok
+}
+
+// Provide 保存 client 返回的 t 类型的值。
+func (runner *runnerContext) Provide(t reflect.Type, v reflect.Value) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	if runner.Dependencies == nil {
+		runner.Dependencies = map[reflect.Type]reflect.Value{}
+	}
+
+	runner.Dependencies[t] = v
+}
+
+// Dependency 返回 client 提供的 t 类型的值。
+func (runner *runnerContext) Dependency(t reflect.Type) (v reflect.Value, ok bool) {
+	runner.mu.RLock()
+	defer runner.mu.RUnlock()
+	v, ok = runner.Dependencies[t]
+	return
+}
+
+// sortClients 根据依赖关系对 clients 排序，被依赖的 client 总是排在前面，
+// 没有依赖关系的 client 会保持注册顺序。
+func sortClients(clients []*clientHandler) ([]*clientHandler, error) {
+	providedBy := map[reflect.Type]*clientHandler{}
+
+	for _, c := range clients {
+		if c.Spec != nil && c.Spec.Provides != nil {
+			providedBy[c.Spec.Provides] = c
+		}
+	}
+
+	sorted := make([]*clientHandler, 0, len(clients))
+	done := map[*clientHandler]bool{}
+
+	isReady := func(c *clientHandler) bool {
+		if c.Spec == nil {
+			return true
+		}
+
+		for _, t := range c.Spec.Requires() {
+			// 没有 client 提供的依赖会在调用 handler 时报错。
+			if p := providedBy[t]; p != nil && !done[p] {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for len(sorted) < len(clients) {
+		var next *clientHandler
+
+		for _, c := range clients {
+			if !done[c] && isReady(c) {
+				next = c
+				break
+			}
+		}
+
+		if next == nil {
+			var callers []string
+
+			for _, c := range clients {
+				if !done[c] {
+					callers = append(callers, c.Caller)
+				}
+			}
+
+			return nil, fmt.Errorf("go-runner: circular dependency among clients [%v]", strings.Join(callers, ", "))
+		}
+
+		done[next] = true
+		sorted = append(sorted, next)
+	}
+
+	return sorted, nil
+}
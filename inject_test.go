@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+type testDB struct{ Addr string }
+type testCache struct{ DB *testDB }
+type testStore interface{ Name() string }
+type testStoreImpl struct{ Cache *testCache }
+
+func (s *testStoreImpl) Name() string { return s.Cache.DB.Addr }
+
+func resetTestInjection() {
+	configHandlers = nil
+	configBindings = nil
+	clientHandlers = nil
+	serverHandlers = nil
+	jobs = nil
+	invalidJobs = nil
+	onStartHandlers = nil
+	onPreStopHandlers = nil
+	registeredHandlers = nil
+	providers = map[reflect.Type]string{}
+}
+
+func TestInjectDependencies(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer resetTestInjection()
+	resetTestInjection()
+
+	var order []string
+	var started, served string
+
+	// 依赖其他 client 的 client 先注册，但是会在依赖之后初始化。
+	AddClient("", func(ctx context.Context, cache *testCache) (testStore, error) {
+		order = append(order, "store")
+		return &testStoreImpl{Cache: cache}, nil
+	})
+	AddClient("foo", func(ctx context.Context, config *fooConfig, db *testDB) *testCache {
+		order = append(order, "cache")
+		a.Equal(config.Bar, 123)
+		return &testCache{DB: db}
+	})
+	AddClient("foo", func(ctx context.Context, config *fooConfig) (*testDB, error) {
+		order = append(order, "db")
+		return &testDB{Addr: "db"}, nil
+	})
+	AddClient("", func(ctx context.Context) {
+		order = append(order, "other")
+	})
+	OnStartHandler(func(ctx context.Context, store testStore) error {
+		started = store.Name()
+		return nil
+	})
+	AddServer("", func(ctx context.Context, db *testDB, store testStore) {
+		served = db.Addr + "+" + store.Name()
+	})
+
+	// *testDB 由 client 提供，不是配置。
+	for _, binding := range configBindings {
+		a.Assert(binding.Type != reflect.TypeOf(&testDB{}))
+	}
+
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(order, []string{"db", "cache", "store", "other"})
+	a.Equal(started, "db")
+	a.Equal(served, "db+db")
+}
+
+func TestInvalidDependencies(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer resetTestInjection()
+
+	// 循环依赖。
+	resetTestInjection()
+	AddClient("", func(ctx context.Context, cache *testCache) *testDB { return nil })
+	AddClient("", func(ctx context.Context, db *testDB) *testCache { return nil })
+	a.Equal(run(), ExitCodeInvalidHandler)
+
+	// 同一个类型只能由一个 client 提供。
+	resetTestInjection()
+	AddClient("", func(ctx context.Context) *testDB { return nil })
+	AddClient("", func(ctx context.Context) *testDB { return nil })
+	a.Equal(run(), ExitCodeInvalidHandler)
+
+	// 依赖没有被任何 client 提供。
+	resetTestInjection()
+	OnStartHandler(func(ctx context.Context, db *testDB) error { return nil })
+	a.Equal(run(), ExitCodeInvalidHandler)
+
+	// client 返回错误时不会提供依赖。
+	resetTestInjection()
+	AddClient("", func(ctx context.Context) (*testDB, error) { return nil, errors.New("error") })
+	a.Equal(run(), ExitCodeHandlerError)
+
+	// 只有 client 才能返回值。
+	resetTestInjection()
+	AddServer("", func(ctx context.Context) *testDB { return nil })
+	a.Equal(run(), ExitCodeInvalidHandler)
+}
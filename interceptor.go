@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/altstory/go-log"
+)
+
+// Phase 是 handler 所在的生命周期阶段。
+type Phase string
+
+// 所有生命周期阶段。
+const (
+	PhaseClient Phase = "client" // PhaseClient 表示通过 AddClient 注册的 handler。
+	PhaseStart  Phase = "start"  // PhaseStart 表示通过 OnStart 注册的 handler。
+	PhaseServer Phase = "server" // PhaseServer 表示通过 AddServer 注册的 handler。
+	PhaseExit   Phase = "exit"   // PhaseExit 表示通过 OnExit 注册的 handler。
+)
+
+// HandlerInfo 是正在执行的 handler 的信息。
+type HandlerInfo struct {
+	Phase   Phase  // Phase 是 handler 所在的生命周期阶段。
+	Section string // Section 是 handler 读取的配置 section，可能为空。
+	Caller  string // Caller 是注册 handler 的调用方。
+}
+
+// Interceptor 拦截 handler 的执行。
+//
+// Interceptor 必须调用 next 才会真正执行 handler，也可以多次调用 next 来实现重试。
+// 如果 handler 执行失败，next 会返回错误；Interceptor 返回的错误决定了这次执行是否成功。
+type Interceptor func(ctx context.Context, info *HandlerInfo, next func(ctx context.Context) error) error
+
+var interceptors []Interceptor
+
+// Use 注册一个 Interceptor，所有 client、OnStart、server、定时任务、OnPreStop 和 OnExit 的 handler 都会经过 interceptor。
+// 先注册的 interceptor 在外层，会先开始执行。
+//
+// 这个函数应该在 init 中调用。
+func Use(interceptor Interceptor) {
+	if interceptor == nil {
+		return
+	}
+
+	interceptors = append(interceptors, interceptor)
+}
+
+// exitCodeError 表示 handler 以 Code 退出。
+type exitCodeError struct {
+	Code int
+}
+
+func (err *exitCodeError) Error() string {
+	return fmt.Sprintf("go-runner: handler fails with exit code %v", err.Code)
+}
+
+// intercept 返回一个经过所有 interceptor 执行 h 的 handler。
+func intercept(info *HandlerInfo, h handler) handler {
+	chain := func(ctx context.Context) int {
+		if len(interceptors) == 0 {
+			return h.CallWithInfo(ctx, info)
+		}
+
+		next := func(ctx context.Context) error {
+			if code := h.CallWithInfo(ctx, info); code != ExitCodeOK {
+				return &exitCodeError{
+					Code: code,
+				}
+			}
+
+			return nil
+		}
+
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next = chainInterceptor(interceptors[i], info, next)
+		}
+
+		err := next(ctx)
+
+		if err == nil {
+			return ExitCodeOK
+		}
+
+		if e, ok := err.(*exitCodeError); ok {
+			return e.Code
+		}
+
+		log.Errorf(ctx, "err=%v||phase=%v||section=%v||caller=%v||go-runner: handler is rejected by interceptor",
+			err, info.Phase, info.Section, info.Caller)
+		return ExitCodeHandlerError
+	}
+
+	// interceptor 自身发生的 panic 也需要带上 handler 的信息。
+	return func(ctx context.Context) int {
+		return handler(chain).CallWithInfo(ctx, info)
+	}
+}
+
+func chainInterceptor(interceptor Interceptor, info *HandlerInfo, next func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return interceptor(ctx, info, next)
+	}
+}
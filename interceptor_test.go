@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestInterceptor(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer func() {
+		resetTestInjection()
+		onExitHandlers = nil
+		interceptors = nil
+	}()
+	resetTestInjection()
+	onExitHandlers = nil
+	interceptors = nil
+
+	var calls []string
+	Use(func(ctx context.Context, info *HandlerInfo, next func(ctx context.Context) error) error {
+		a.Assert(strings.HasPrefix(info.Caller, "interceptor_test.go:"))
+		calls = append(calls, "outer:"+string(info.Phase)+":"+info.Section)
+		return next(ctx)
+	})
+
+	// 失败的 handler 会被重试一次。
+	Use(func(ctx context.Context, info *HandlerInfo, next func(ctx context.Context) error) error {
+		calls = append(calls, "inner")
+
+		if err := next(ctx); err != nil {
+			calls = append(calls, "retry")
+			return next(ctx)
+		}
+
+		return nil
+	})
+
+	failed := false
+	AddClient("foo", func(ctx context.Context, c *fooConfig) error {
+		if !failed {
+			failed = true
+			return errors.New("error")
+		}
+
+		return nil
+	})
+	OnStart(func(ctx context.Context) error { return nil })
+	AddServerSections([]string{"foo", "http.server"}, func(ctx context.Context, f *fooConfig, s *testServerConfig) {})
+	OnExit(func(ctx context.Context) {})
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(calls, []string{
+		"outer:client:foo", "inner", "retry",
+		"outer:start:", "inner",
+		"outer:server:foo,http.server", "inner",
+		"outer:exit:", "inner",
+	})
+
+	// interceptor 返回的错误会让 handler 失败，handler 本身的错误码会被保留。
+	interceptors = nil
+	Use(func(ctx context.Context, info *HandlerInfo, next func(ctx context.Context) error) error {
+		if info.Phase == PhaseServer {
+			return errors.New("rejected")
+		}
+
+		return next(ctx)
+	})
+	a.Equal(run(), ExitCodeHandlerError)
+
+	// handler 不合法时 next 会返回对应的错误码。
+	interceptors = nil
+	Use(func(ctx context.Context, info *HandlerInfo, next func(ctx context.Context) error) error {
+		return next(ctx)
+	})
+	resetTestInjection()
+	AddServer("", 1)
+	a.Equal(run(), ExitCodeInvalidHandler)
+}
@@ -0,0 +1,332 @@
+package runner
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/altstory/go-log"
+)
+
+// PhaseJob 表示通过 AddJob 注册的定时任务。
+const PhaseJob Phase = "job"
+
+// JobOption 是 AddJob 的可选参数。
+type JobOption func(opts *jobOptions)
+
+type jobOptions struct {
+	Name         string
+	AllowOverlap bool
+	Jitter       time.Duration
+	Timeout      time.Duration
+}
+
+// JobName 设置定时任务的名字，默认使用 section，section 为空时使用注册定时任务的调用方。
+func JobName(name string) JobOption {
+	return func(opts *jobOptions) {
+		opts.Name = name
+	}
+}
+
+// AllowOverlap 允许定时任务在上一次执行还没结束时开始新的执行。
+// 默认情况下，如果上一次执行还没结束，这一次执行会被跳过。
+func AllowOverlap() JobOption {
+	return func(opts *jobOptions) {
+		opts.AllowOverlap = true
+	}
+}
+
+// Jitter 让定时任务每次执行时随机推迟 [0, jitter) 的时间，避免大量实例在同一时刻执行。
+func Jitter(jitter time.Duration) JobOption {
+	return func(opts *jobOptions) {
+		opts.Jitter = jitter
+	}
+}
+
+// JobTimeout 设置定时任务每次执行的超时时间，超时之后传给 handler 的 ctx 会被取消。
+func JobTimeout(timeout time.Duration) JobOption {
+	return func(opts *jobOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+// Clock 是定时任务使用的时钟。
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+var jobClock struct {
+	mu    sync.Mutex
+	clock Clock
+}
+
+// SetJobClock 设置定时任务使用的时钟，设置为 nil 表示使用系统时钟。
+// 这个函数主要用于在单元测试中用假的时钟控制定时任务的执行。
+func SetJobClock(clock Clock) {
+	jobClock.mu.Lock()
+	defer jobClock.mu.Unlock()
+	jobClock.clock = clock
+}
+
+func currentJobClock() Clock {
+	jobClock.mu.Lock()
+	defer jobClock.mu.Unlock()
+
+	if jobClock.clock == nil {
+		return realClock{}
+	}
+
+	return jobClock.clock
+}
+
+// JobStatus 是定时任务的执行统计。
+type JobStatus struct {
+	Name         string        `json:"name"`          // Name 是定时任务的名字。
+	Schedule     string        `json:"schedule"`      // Schedule 是定时任务的执行计划。
+	Caller       string        `json:"caller"`        // Caller 是注册定时任务的调用方。
+	Running      int           `json:"running"`       // Running 是正在执行的次数。
+	Runs         int           `json:"runs"`          // Runs 是已经执行完成的次数。
+	Failures     int           `json:"failures"`      // Failures 是执行失败的次数，包括超时。
+	Timeouts     int           `json:"timeouts"`      // Timeouts 是执行超时的次数。
+	Skips        int           `json:"skips"`         // Skips 是因为上一次执行还没结束而跳过的次数。
+	LastStart    time.Time     `json:"last_start"`    // LastStart 是最近一次开始执行的时间。
+	LastDuration time.Duration `json:"last_duration"` // LastDuration 是最近一次执行的耗时。
+	Next         time.Time     `json:"next"`          // Next 是下一次计划执行的时间，不包括随机推迟的时间。
+}
+
+// job 是一个通过 AddJob 注册的定时任务。
+type job struct {
+	jobOptions
+
+	Schedule schedule
+	Handler  handler
+	Rand     *rand.Rand // Rand 用于计算随机推迟的时间，只在 Serve 中使用。
+
+	mu     sync.Mutex
+	status JobStatus
+}
+
+var jobs []*job
+
+// invalidJobs 是注册时就已经发现错误的定时任务，例如执行计划无法解析，
+// 服务会在执行任何 client 和 OnStart 注册的函数之前以 ExitCodeInvalidHandler 退出。
+var invalidJobs []handler
+
+// AddJob 注册一个定时任务，handler 的格式与 AddServer 相同，每次执行时都会读取 section 的最新配置。
+//
+// schedule 可以是标准的 5 个字段的 cron 表达式，例如 "*/5 * * * *"，也可以是 @daily、@hourly 等预定义表达式，
+// 或者是 "@every 30s" 这样的固定间隔。
+//
+// 定时任务会在所有 server 开始运行时开始调度，在所有 server 返回或者服务退出时停止调度，
+// 并等待正在执行的任务结束，最长等待 [runner] 中 shutdown_timeout 设置的时间。
+// 如果没有注册任何 server，定时任务会一直调度到服务退出。
+func AddJob(section string, schedule string, handler Handler, opts ...JobOption) {
+	const skip = 1
+	caller := findCaller(skip)
+	sched, err := parseSchedule(schedule)
+
+	if err != nil {
+		invalidJobs = append(invalidJobs, makeErrorHandler(skip, err))
+		return
+	}
+
+	spec, err := registerHandlerSpec(skip, []string{section}, handler, false)
+
+	if err != nil {
+		invalidJobs = append(invalidJobs, makeErrorHandler(skip, err))
+		return
+	}
+
+	info := &HandlerInfo{
+		Phase:   PhaseJob,
+		Section: section,
+		Caller:  caller,
+	}
+	j := &job{
+		Schedule: sched,
+		Handler:  intercept(info, spec.Handler()),
+		Rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		status: JobStatus{
+			Schedule: schedule,
+			Caller:   caller,
+		},
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&j.jobOptions)
+		}
+	}
+
+	if j.Name == "" {
+		j.Name = section
+	}
+
+	if j.Name == "" {
+		j.Name = caller
+	}
+
+	j.status.Name = j.Name
+	jobs = append(jobs, j)
+}
+
+// checkJobs 报告所有不合法的定时任务，只要有一个不合法就返回 ExitCodeInvalidHandler。
+func checkJobs(ctx context.Context) int {
+	code := ExitCodeOK
+
+	for _, h := range invalidJobs {
+		if c := h.Call(ctx); c != ExitCodeOK {
+			code = c
+		}
+	}
+
+	return code
+}
+
+// Jobs 返回所有定时任务的执行统计，按照名字排序。
+func Jobs() []*JobStatus {
+	list := make([]*JobStatus, 0, len(jobs))
+
+	for _, j := range jobs {
+		list = append(list, j.Status())
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Status 返回定时任务的执行统计。
+func (j *job) Status() *JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := j.status
+	return &status
+}
+
+// Serve 按照执行计划调度定时任务，直到 ctx 被取消为止。
+func (j *job) Serve(ctx context.Context) int {
+	clock := currentJobClock()
+	wg := sync.WaitGroup{}
+	defer j.wait(ctx, &wg)
+
+	j.mu.Lock()
+	j.status = JobStatus{
+		Name:     j.status.Name,
+		Schedule: j.status.Schedule,
+		Caller:   j.status.Caller,
+	}
+	j.mu.Unlock()
+
+	for {
+		now := clock.Now()
+		next := j.Schedule.Next(now)
+
+		if next.IsZero() {
+			log.Warnf(ctx, "name=%v||schedule=%v||go-runner: job will never run", j.Name, j.status.Schedule)
+			<-ctx.Done()
+			return ExitCodeOK
+		}
+
+		j.mu.Lock()
+		j.status.Next = next
+		j.mu.Unlock()
+
+		delay := next.Sub(now)
+
+		if j.Jitter > 0 {
+			delay += time.Duration(j.Rand.Int63n(int64(j.Jitter)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ExitCodeOK
+		case <-clock.After(delay):
+		}
+
+		j.mu.Lock()
+
+		if j.status.Running > 0 && !j.AllowOverlap {
+			j.status.Skips++
+			j.mu.Unlock()
+			log.Warnf(ctx, "name=%v||go-runner: job is skipped because last run is not finished", j.Name)
+			continue
+		}
+
+		start := clock.Now()
+		j.status.Running++
+		j.status.LastStart = start
+		j.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.run(ctx, clock, start)
+		}()
+	}
+}
+
+// wait 等待正在执行的任务结束，最长等待 [runner] 中 shutdown_timeout 设置的时间。
+func (j *job) wait(ctx context.Context, wg *sync.WaitGroup) {
+	done := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timeout := runnerFromContext(ctx).RunnerConfig.ShutdownTimeout
+	var expired <-chan time.Time
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-done:
+	case <-expired:
+		log.Errorf(ctx, "name=%v||running=%v||timeout=%v||go-runner: job does not stop in time", j.Name, j.Status().Running, timeout)
+	}
+}
+
+// run 执行一次定时任务，执行耗时使用 clock 计算，与 LastStart 使用同一个时钟。
+func (j *job) run(ctx context.Context, clock Clock, start time.Time) {
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	code := j.Handler.Call(ctx)
+	duration := clock.Now().Sub(start)
+	timeout := code != ExitCodeOK && ctx.Err() == context.DeadlineExceeded
+
+	if timeout {
+		log.Errorf(ctx, "name=%v||timeout=%v||go-runner: job times out", j.Name, j.Timeout)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status.Running--
+	j.status.Runs++
+	j.status.LastDuration = duration
+
+	if code != ExitCodeOK {
+		j.status.Failures++
+	}
+
+	if timeout {
+		j.status.Timeouts++
+	}
+}
@@ -0,0 +1,297 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+// testClock 是一个只有在调用 Advance 时才会前进的时钟。
+type testClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*testTimer
+}
+
+type testTimer struct {
+	At time.Time
+	C  chan time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{
+		now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (clock *testClock) Now() time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	return clock.now
+}
+
+func (clock *testClock) After(d time.Duration) <-chan time.Time {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+
+	timer := &testTimer{
+		At: clock.now.Add(d),
+		C:  make(chan time.Time, 1),
+	}
+	clock.timers = append(clock.timers, timer)
+	return timer.C
+}
+
+// Advance 等待 n 个计时器都开始等待，然后让时钟前进到最早的计时器的时间。
+// 时间相同的计时器中，先开始等待的先触发。
+func (clock *testClock) Advance(n int) {
+	for {
+		clock.mu.Lock()
+
+		if len(clock.timers) >= n {
+			break
+		}
+
+		clock.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	defer clock.mu.Unlock()
+	next := 0
+
+	for i, timer := range clock.timers {
+		if timer.At.Before(clock.timers[next].At) {
+			next = i
+		}
+	}
+
+	timer := clock.timers[next]
+	clock.timers = append(clock.timers[:next], clock.timers[next+1:]...)
+	clock.now = timer.At
+	timer.C <- timer.At
+}
+
+// waitJob 等待名字为 name 的定时任务满足 cond。
+func waitJob(name string, cond func(status *JobStatus) bool) {
+	for {
+		for _, status := range Jobs() {
+			if status.Name == name && cond(status) {
+				return
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAddJob(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer func() {
+		resetTestInjection()
+		SetJobClock(nil)
+	}()
+	resetTestInjection()
+
+	clock := newTestClock()
+	SetJobClock(clock)
+
+	ran := make(chan int, 10)
+	release := make(chan bool)
+	AddJob("foo", "@every 1m", func(ctx context.Context, config *fooConfig) error {
+		ran <- config.Bar
+		<-release
+		return nil
+	})
+	AddJob("", "*/5 * * * *", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, JobName("timeout"), JobTimeout(10*time.Millisecond))
+
+	var statuses []*JobStatus
+	AddServer("", func(ctx context.Context) {
+		// foo 在 00:01 第一次执行，读取最新的配置。
+		clock.Advance(2)
+		a.Equal(<-ran, 123)
+
+		// 00:02 时上一次执行还没结束，这一次执行被跳过。
+		clock.Advance(2)
+		waitJob("foo", func(status *JobStatus) bool { return status.Skips == 1 })
+		close(release)
+		waitJob("foo", func(status *JobStatus) bool { return status.Runs == 1 })
+
+		// foo 在 00:03 和 00:04 正常执行，timeout 在 00:05 执行并超时。
+		clock.Advance(2)
+		a.Equal(<-ran, 123)
+		clock.Advance(2)
+		a.Equal(<-ran, 123)
+		clock.Advance(2)
+		waitJob("foo", func(status *JobStatus) bool { return status.Runs == 3 })
+		waitJob("timeout", func(status *JobStatus) bool { return status.Timeouts == 1 })
+
+		// server 返回之后，定时任务会停止调度。
+		statuses = Jobs()
+	})
+
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(len(statuses), 2)
+
+	foo := statuses[0]
+	a.Equal(foo.Name, "foo")
+	a.Equal(foo.Schedule, "@every 1m")
+	a.Equal(foo.Runs, 3)
+	a.Equal(foo.Skips, 1)
+	a.Equal(foo.Failures, 0)
+
+	timeout := statuses[1]
+	a.Equal(timeout.Name, "timeout")
+	a.Equal(timeout.Runs, 1)
+	a.Equal(timeout.Failures, 1)
+	a.Equal(timeout.Timeouts, 1)
+	a.Equal(timeout.LastStart, time.Date(2020, 1, 1, 0, 5, 0, 0, time.UTC))
+
+	// 耗时使用同一个时钟计算，执行期间时钟没有前进。
+	a.Equal(timeout.LastDuration, time.Duration(0))
+}
+
+func TestJobJitter(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer func() {
+		resetTestInjection()
+		SetJobClock(nil)
+	}()
+	resetTestInjection()
+
+	clock := newTestClock()
+	SetJobClock(clock)
+
+	AddJob("", "@every 1m", func(ctx context.Context) error {
+		return nil
+	}, Jitter(time.Minute))
+
+	var at time.Time
+	AddServer("", func(ctx context.Context) {
+		for at.IsZero() {
+			clock.mu.Lock()
+
+			if len(clock.timers) > 0 {
+				at = clock.timers[0].At
+			}
+
+			clock.mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	a.Equal(run(), ExitCodeOK)
+
+	// 执行时间随机推迟 [0, 1m)。
+	next := time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)
+	a.Assert(!at.Before(next))
+	a.Assert(at.Before(next.Add(time.Minute)))
+}
+
+func TestJobShutdownTimeout(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[runner]\nshutdown_timeout = \"20ms\"\n", filepath.Join(dir, "log", "test.log")))
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		resetTestInjection()
+		SetJobClock(nil)
+	}()
+	resetTestInjection()
+
+	clock := newTestClock()
+	SetJobClock(clock)
+
+	started := make(chan bool)
+	block := make(chan bool)
+	stopped := make(chan bool)
+	AddJob("", "@every 1m", func(ctx context.Context) error {
+		defer close(stopped)
+		close(started)
+		<-block
+		return nil
+	}, JobName("stuck"))
+	AddServer("", func(ctx context.Context) {
+		clock.Advance(1)
+		<-started
+	})
+
+	// 定时任务没有及时结束时，服务不会一直等待。
+	start := time.Now()
+	a.Equal(run(), ExitCodeOK)
+	a.Assert(time.Since(start) < time.Second)
+
+	close(block)
+	<-stopped
+}
+
+func TestInvalidJob(t *testing.T) {
+	a := assert.New(t)
+
+	defer resetTestInjection()
+	resetTestInjection()
+
+	AddJob("", "* * *", func(ctx context.Context) error {
+		return nil
+	})
+	AddJob("", "@every 1s", func(ctx context.Context) int {
+		return 0
+	})
+	err := Validate()
+	a.NonNilError(err)
+	a.Equal(len(err.(handlerErrors)), 2)
+
+	// 不合法的定时任务会在执行任何 client 和 OnStart 注册的函数之前被拒绝。
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	started := false
+	AddClient("", func(ctx context.Context) {
+		started = true
+	})
+	OnStart(func(ctx context.Context) error {
+		started = true
+		return nil
+	})
+	a.Equal(run(), ExitCodeInvalidHandler)
+	a.Assert(!started)
+
+	resetTestInjection()
+	AddJob("", "@every 1s", func(ctx context.Context) error {
+		return errors.New("error")
+	})
+	a.NilError(Validate())
+}
@@ -0,0 +1,218 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/altstory/go-config"
+)
+
+const (
+	envRunnerExtConfig = "ALTSTORY_RUNNER_EXT_CONFIG"
+
+	confDirName = "conf.d"
+	confFileExt = ".conf"
+)
+
+// configFile 是一个需要加载的配置文件。
+type configFile struct {
+	Name string // Name 是配置文件的名字，对于本地文件来说就是文件路径。
+	Path string // Path 是本地文件路径。
+	Data []byte // Data 是来自 ConfigSource 的配置内容，加载时会先保存到一个本地临时文件中。
+}
+
+func (f *configFile) String() string {
+	return f.Name
+}
+
+// Open 将 f 作为主配置文件加载。
+func (f *configFile) Open() (c *config.Config, err error) {
+	err = f.withLocalFile(func(path string) (err error) {
+		c, err = config.LoadFile(path)
+		return
+	})
+	return
+}
+
+// ApplyTo 将 f 作为额外配置文件追加到 c 中。
+func (f *configFile) ApplyTo(c *config.Config) error {
+	return f.withLocalFile(c.LoadExt)
+}
+
+// withLocalFile 用 f 对应的本地文件路径调用 fn，如果 f 的内容不在本地，会先写入一个临时文件。
+func (f *configFile) withLocalFile(fn func(path string) error) error {
+	if f.Data == nil {
+		return fn(f.Path)
+	}
+
+	tmp, err := ioutil.TempFile("", "go-runner-*"+confFileExt)
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(f.Data)
+
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return fn(tmp.Name())
+}
+
+// loadConfigFiles 加载主配置文件 path、所有额外的配置文件以及 ConfigSource 中的配置，
+// 返回合并后的配置和按加载顺序排列的所有配置文件。
+func loadConfigFiles(ctx context.Context, path string) (c *config.Config, files []*configFile, err error) {
+	runner := runnerFromContext(ctx)
+	var layers []*ConfigLayer
+
+	if runner.Source == nil && *flagConfigSource != "" {
+		source, e := newConfigSource(*flagConfigSource)
+
+		if e != nil {
+			err = fmt.Errorf("go-runner: fail to create config source %v: %v", *flagConfigSource, e)
+			return
+		}
+
+		runner.Source = source
+	}
+
+	if runner.Source != nil {
+		layers, err = runner.Source.Load(ctx)
+
+		if err != nil {
+			err = fmt.Errorf("go-runner: fail to load config source %v: %v", *flagConfigSource, err)
+			return
+		}
+	}
+
+	return mergeConfigFiles(path, layers)
+}
+
+// mergeConfigFiles 依次加载主配置文件 path、所有额外的配置文件以及 layers，返回合并后的配置。
+func mergeConfigFiles(path string, layers []*ConfigLayer) (c *config.Config, files []*configFile, err error) {
+	main := &configFile{
+		Name: path,
+		Path: path,
+	}
+	c, err = main.Open()
+
+	if err != nil {
+		err = fmt.Errorf("go-runner: fail to parse config file %v: %v", path, err)
+		return
+	}
+
+	// 依次加载环境配置、conf.d 目录和环境变量中设置的额外配置文件。
+	extPaths, err := extConfigFiles(path)
+
+	if err != nil {
+		err = fmt.Errorf("go-runner: fail to find extension config files of %v: %v", path, err)
+		return
+	}
+
+	files = append(files, main)
+
+	for _, extPath := range extPaths {
+		files = append(files, &configFile{
+			Name: extPath,
+			Path: extPath,
+		})
+	}
+
+	// ConfigSource 中的配置最后加载，优先级最高。
+	for _, layer := range layers {
+		files = append(files, &configFile{
+			Name: layer.Name,
+			Data: layer.Data,
+		})
+	}
+
+	for _, f := range files[1:] {
+		err = f.ApplyTo(c)
+
+		if err != nil {
+			err = fmt.Errorf("go-runner: fail to parse extension config file %v: %v", f, err)
+			return
+		}
+	}
+
+	return
+}
+
+// extConfigFiles 返回所有需要通过 LoadExt 追加到主配置文件 path 上的配置文件，按加载顺序排列。
+//
+// 加载顺序如下，越靠后的配置优先级越高：
+//     - 与当前环境相关的配置文件，详见 envConfigFiles；
+//     - 主配置文件同级目录下 conf.d 目录里所有 .conf 文件，按文件名字典序排列；
+//     - 环境变量 ALTSTORY_RUNNER_EXT_CONFIG 里设置的文件，多个文件之间用“:”分隔。
+func extConfigFiles(path string) (files []string, err error) {
+	files = envConfigFiles(path, Meta())
+	confDir := filepath.Join(filepath.Dir(path), confDirName)
+	infos, err := ioutil.ReadDir(confDir)
+
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return
+		}
+
+		err = nil
+	}
+
+	// ioutil.ReadDir 返回的文件已经按照文件名排好序。
+	for _, info := range infos {
+		name := info.Name()
+
+		if info.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != confFileExt {
+			continue
+		}
+
+		files = append(files, filepath.Join(confDir, name))
+	}
+
+	if extPaths, exists := os.LookupEnv(envRunnerExtConfig); exists {
+		for _, extPath := range filepath.SplitList(extPaths) {
+			if extPath == "" {
+				continue
+			}
+
+			files = append(files, extPath)
+		}
+	}
+
+	return
+}
+
+// envConfigFiles 根据 meta 中的环境信息返回主配置文件 path 同级目录下存在的环境配置文件。
+//
+// 假如主配置文件是 service.conf，当前环境是 production，namespace 是 foo，
+// 那么会依次查找 service.production.conf 和 service.production.foo.conf。
+func envConfigFiles(path string, meta *MetaInfo) (files []string) {
+	if meta.Env == "" {
+		return
+	}
+
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(path, ext)
+	candidates := []string{prefix + "." + meta.Env + ext}
+
+	if meta.Namespace != "" {
+		candidates = append(candidates, prefix+"."+meta.Env+"."+meta.Namespace+ext)
+	}
+
+	for _, file := range candidates {
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			files = append(files, file)
+		}
+	}
+
+	return
+}
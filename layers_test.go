@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func writeTestFile(a *assert.A, path, content string) {
+	a.NilError(os.MkdirAll(filepath.Dir(path), 0755))
+	a.NilError(ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestExtConfigLayers(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[foo]\nbar = 1\n", filepath.Join(dir, "log", "test.log")))
+	writeTestFile(a, filepath.Join(dir, "conf", "conf.d", "20-second.conf"), "[foo]\nbar = 3\n")
+	writeTestFile(a, filepath.Join(dir, "conf", "conf.d", "10-first.conf"), "[foo]\nbar = 2\nbaz = 2\n")
+	writeTestFile(a, filepath.Join(dir, "conf", "conf.d", "ignored.txt"), "[foo]\nbar = 100\n")
+	writeTestFile(a, filepath.Join(dir, "ext", "a.conf"), "[foo]\nbaz = 4\n")
+	writeTestFile(a, filepath.Join(dir, "ext", "b.conf"), "_deletes = ['foo.bar']\n")
+
+	extA := filepath.Join(dir, "ext", "a.conf")
+	extB := filepath.Join(dir, "ext", "b.conf")
+	a.NilError(os.Setenv(envRunnerExtConfig, extA+string(filepath.ListSeparator)+extB))
+	defer os.Unsetenv(envRunnerExtConfig)
+
+	files, err := extConfigFiles(confPath)
+	a.NilError(err)
+	a.Equal(files, []string{
+		filepath.Join(dir, "conf", "conf.d", "10-first.conf"),
+		filepath.Join(dir, "conf", "conf.d", "20-second.conf"),
+		extA,
+		extB,
+	})
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		clientHandlers = nil
+	}()
+
+	type layeredConfig struct {
+		Bar int `config:"bar"`
+		Baz int `config:"baz"`
+	}
+
+	var loaded *layeredConfig
+	clientHandlers = nil
+	AddClient("foo", func(ctx context.Context, c *layeredConfig) {
+		loaded = c
+	})
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(loaded, &layeredConfig{
+		Bar: 0,
+		Baz: 4,
+	})
+}
+
+func TestEnvConfigLayers(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[foo]\nbar = 1\nbaz = 1\n", filepath.Join(dir, "log", "test.log")))
+	writeTestFile(a, filepath.Join(dir, "conf", "service.production.conf"), "[foo]\nbar = 2\nbaz = 2\n")
+	writeTestFile(a, filepath.Join(dir, "conf", "service.production.foo.conf"), "[foo]\nbaz = 3\n")
+	writeTestFile(a, filepath.Join(dir, "conf", "conf.d", "override.conf"), "[foo]\nbar = 4\n")
+
+	oldMeta := metaInfo
+	defer func() {
+		metaInfo = oldMeta
+	}()
+
+	metaInfo = MetaInfo{Env: "production", Namespace: "foo"}
+	files, err := extConfigFiles(confPath)
+	a.NilError(err)
+	a.Equal(files, []string{
+		filepath.Join(dir, "conf", "service.production.conf"),
+		filepath.Join(dir, "conf", "service.production.foo.conf"),
+		filepath.Join(dir, "conf", "conf.d", "override.conf"),
+	})
+
+	metaInfo = MetaInfo{Env: "development", Namespace: "foo"}
+	a.Equal(envConfigFiles(confPath, Meta()), nil)
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		clientHandlers = nil
+	}()
+
+	type layeredConfig struct {
+		Bar int `config:"bar"`
+		Baz int `config:"baz"`
+	}
+
+	var loaded *layeredConfig
+	metaInfo = MetaInfo{Env: "production", Namespace: "foo"}
+	clientHandlers = nil
+	AddClient("foo", func(ctx context.Context, c *layeredConfig) {
+		loaded = c
+	})
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(loaded, &layeredConfig{
+		Bar: 4,
+		Baz: 3,
+	})
+}
@@ -2,22 +2,73 @@ package runner
 
 import (
 	"context"
+
+	"github.com/altstory/go-log"
 )
 
-var onExitHandlers handlers
+var onExitHandlers []*hook
 
-// OnExit 将 handler 注册到 runner 的启动列表里面。
-func OnExit(handler func(ctx context.Context)) {
+// OnExit 将 handler 注册到 runner 的退出列表里面。
+//
+// 所有 handler 会按照与启动顺序相反的顺序执行：默认情况下后注册的 handler 先执行，就像 defer 一样。
+// opts 的含义与 OnStart 完全相同，描述的是启动时的先后顺序，详见 HookOption。
+// 如果通过 HookName 设置了与 OnStart 中某个函数相同的名字，handler 会按照同名启动函数的实际启动顺序反向执行。
+func OnExit(handler func(ctx context.Context), opts ...HookOption) {
 	if handler == nil {
 		return
 	}
 
-	onExitHandlers = append(onExitHandlers, func(ctx context.Context) int {
+	addExitHandler(1, func(ctx context.Context) int {
 		handler(ctx)
 		return ExitCodeOK
-	})
+	}, opts)
+}
+
+// OnExitE 与 OnExit 类似，区别是 handler 可以返回错误。
+//
+// handler 返回的错误会和注册 handler 的调用方一起记录在日志里，不会影响其他 handler 的执行。
+// 如果 [runner] 中 fail_on_exit_error 为 true，原本正常退出的服务会改为以 ExitCodeHandlerError 退出。
+func OnExitE(handler func(ctx context.Context) error, opts ...HookOption) {
+	if handler == nil {
+		return
+	}
+
+	caller := findCaller(1)
+	addExitHandler(1, func(ctx context.Context) int {
+		if err := handler(ctx); err != nil {
+			log.Errorf(ctx, "err=%v||caller=%v||go-runner: exit handler fails", err, caller)
+			return ExitCodeHandlerError
+		}
+
+		return ExitCodeOK
+	}, opts)
 }
 
+func addExitHandler(skip int, h handler, opts []HookOption) {
+	caller := findCaller(skip + 1)
+	info := &HandlerInfo{
+		Phase:  PhaseExit,
+		Caller: caller,
+	}
+	onExitHandlers = append(onExitHandlers, newHook(intercept(info, h), caller, opts))
+}
+
+// runExitHandlers 执行所有退出函数，即使有函数执行失败也会继续执行剩下的函数，返回第一个失败的错误码。
 func runExitHandlers(ctx context.Context) int {
-	return onExitHandlers.Call(ctx)
+	hooks, err := sortExitHooks(onStartHandlers, onExitHandlers)
+
+	if err != nil {
+		log.Errorf(ctx, "err=%v||go-runner: invalid order of exit handlers and fall back to reverse registration order", err)
+		hooks = onExitHandlers
+	}
+
+	code := ExitCodeOK
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if c := hooks[i].Handler.Call(ctx); c != ExitCodeOK && code == ExitCodeOK {
+			code = c
+		}
+	}
+
+	return code
 }
@@ -2,8 +2,12 @@ package runner
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"testing"
 
 	"github.com/huandu/go-assert"
@@ -29,3 +33,49 @@ func TestOnExit(t *testing.T) {
 	a.Equal(run(), ExitCodeOK)
 	a.Assert(touched)
 }
+
+func TestOnExitE(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n", filepath.Join(dir, "log", "test.log")))
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		onExitHandlers = nil
+	}()
+
+	var calls []string
+	register := func() {
+		onExitHandlers = nil
+		calls = nil
+		OnExit(func(ctx context.Context) {
+			calls = append(calls, "first")
+		})
+		OnExitE(func(ctx context.Context) error {
+			calls = append(calls, "second")
+			return errors.New("error")
+		})
+		OnExitE(func(ctx context.Context) error {
+			calls = append(calls, "third")
+			return nil
+		})
+	}
+
+	// 默认情况下，退出函数的错误只会记录日志，不影响错误码。
+	register()
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(calls, []string{"third", "second", "first"})
+
+	// 开启 fail_on_exit_error 之后，正常退出的服务会返回错误码。
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[runner]\nfail_on_exit_error = true\n", filepath.Join(dir, "log", "test.log")))
+	register()
+	a.Equal(run(), ExitCodeHandlerError)
+	a.Equal(calls, []string{"third", "second", "first"})
+}
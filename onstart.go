@@ -2,29 +2,124 @@ package runner
 
 import (
 	"context"
+	"time"
 
 	"github.com/altstory/go-log"
 )
 
-var onStartHandlers handlers
+var onStartHandlers []*hook
 
 // OnStart 将 handler 注册到 runner 的启动列表里面。
-func OnStart(handler func(ctx context.Context) error) {
+// 如果 handler 返回错误，服务会停止启动并退出。
+//
+// 默认情况下，所有 handler 按照注册顺序执行，可以通过 opts 指定 handler 的名字、优先级和先后顺序，详见 HookOption。
+// 每个 handler 的执行时间受 [runner] 中 start_timeout 限制，也可以通过 HookTimeout 单独设置，
+// 超时后服务会以 ExitCodeStartTimeout 退出。
+func OnStart(handler func(ctx context.Context) error, opts ...HookOption) {
 	if handler == nil {
 		return
 	}
 
-	caller := findCaller(1)
-	onStartHandlers = append(onStartHandlers, func(ctx context.Context) int {
-		if err := handler(ctx); err != nil {
-			log.Errorf(ctx, "err=%v||caller=%v||go-runner: fail to call handler", err, caller)
-			return ExitCodeHandlerError
-		}
+	const skip = 1
+	addStartHandler(skip+1, handler, opts)
+}
+
+// OnStartHandler 与 OnStart 类似，区别是 handler 在 context.Context 之后可以声明任意多个由 client 提供的依赖，
+// 例如 func(ctx context.Context, client *redis.Client) error。
+func OnStartHandler(handler Handler, opts ...HookOption) {
+	if isNilHandler(handler) {
+		return
+	}
 
-		return ExitCodeOK
-	})
+	const skip = 1
+	addStartHandler(skip+1, handler, opts)
+}
+
+func addStartHandler(skip int, handler Handler, opts []HookOption) {
+	caller := findCaller(skip)
+	info := &HandlerInfo{
+		Phase:  PhaseStart,
+		Caller: caller,
+	}
+	spec, err := registerHandlerSpec(skip, nil, handler, false)
+
+	if err != nil {
+		onStartHandlers = append(onStartHandlers, newHook(measure(info, intercept(info, makeErrorHandler(skip, err))), caller, opts))
+		return
+	}
+
+	onStartHandlers = append(onStartHandlers, newHook(measure(info, intercept(info, spec.Handler())), caller, opts))
 }
 
 func runStartHandlers(ctx context.Context) int {
-	return onStartHandlers.Call(ctx)
+	hooks, err := sortHooks(onStartHandlers)
+
+	if err != nil {
+		log.Errorf(ctx, "err=%v||go-runner: invalid order of start handlers", err)
+		return ExitCodeInvalidHandler
+	}
+
+	defaultTimeout := runnerFromContext(ctx).RunnerConfig.StartTimeout
+
+	for _, hk := range hooks {
+		timeout := hk.Timeout
+
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+
+		if code := callStartHandler(ctx, hk, timeout); code != ExitCodeOK {
+			return code
+		}
+	}
+
+	return ExitCodeOK
+}
+
+// callStartHandler 执行 hk，timeout 大于 0 时最多等待 timeout 时间。
+//
+// 超时之后 ctx 会被取消，此时仍然会等待 handler 返回，最长等待 [runner] 中 shutdown_timeout 设置的时间，
+// 避免 handler 在服务退出的过程中继续访问已经被清理的资源。
+func callStartHandler(ctx context.Context, hk *hook, timeout time.Duration) int {
+	if timeout <= 0 {
+		return hk.Handler.Call(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan int, 1)
+	go func() {
+		done <- hk.Handler.Call(ctx)
+	}()
+
+	select {
+	case code := <-done:
+		if code == ExitCodeOK || ctx.Err() != context.DeadlineExceeded {
+			return code
+		}
+	case <-ctx.Done():
+		waitStartHandler(ctx, hk, done)
+	}
+
+	log.Errorf(ctx, "timeout=%v||caller=%v||go-runner: start handler times out", timeout, hk.Caller)
+	return ExitCodeStartTimeout
+}
+
+// waitStartHandler 等待超时的 handler 返回，最长等待 [runner] 中 shutdown_timeout 设置的时间。
+func waitStartHandler(ctx context.Context, hk *hook, done <-chan int) {
+	shutdownTimeout := runnerFromContext(ctx).RunnerConfig.ShutdownTimeout
+	var expired <-chan time.Time
+
+	if shutdownTimeout > 0 {
+		timer := time.NewTimer(shutdownTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-done:
+	case <-expired:
+		log.Errorf(ctx, "timeout=%v||caller=%v||go-runner: start handler does not return in time after timing out", shutdownTimeout, hk.Caller)
+	}
 }
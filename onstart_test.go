@@ -3,9 +3,13 @@ package runner
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/huandu/go-assert"
 )
@@ -37,3 +41,125 @@ func TestOnStart(t *testing.T) {
 	})
 	a.Equal(run(), ExitCodeHandlerError)
 }
+
+func TestOnStartTimeout(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer func() {
+		onStartHandlers = nil
+	}()
+
+	// 超时之后 ctx 会被取消，handler 返回的错误被当做超时处理。
+	onStartHandlers = nil
+	cancelled := make(chan bool, 1)
+	OnStart(func(ctx context.Context) error {
+		<-ctx.Done()
+		cancelled <- true
+		return ctx.Err()
+	}, HookTimeout(10*time.Millisecond))
+	a.Equal(run(), ExitCodeStartTimeout)
+	a.Assert(<-cancelled)
+
+	// 超时之后会等待 handler 返回再退出。
+	onStartHandlers = nil
+	returned := make(chan bool, 1)
+	OnStart(func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		returned <- true
+		return nil
+	}, HookTimeout(10*time.Millisecond))
+	a.Equal(run(), ExitCodeStartTimeout)
+
+	select {
+	case <-returned:
+	default:
+		t.Fatalf("start handler should return before run exits")
+	}
+
+	// 没有超时的 handler 正常执行。
+	onStartHandlers = nil
+	OnStart(func(ctx context.Context) error {
+		_, ok := ctx.Deadline()
+		a.Assert(ok)
+		return nil
+	}, HookTimeout(time.Second))
+	a.Equal(run(), ExitCodeOK)
+
+	// 普通错误不会被当做超时。
+	onStartHandlers = nil
+	OnStart(func(ctx context.Context) error {
+		return errors.New("error")
+	}, HookTimeout(time.Second))
+	a.Equal(run(), ExitCodeHandlerError)
+}
+
+func TestDefaultStartTimeout(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[runner]\nstart_timeout = \"10ms\"\nshutdown_timeout = \"10ms\"\n", filepath.Join(dir, "log", "test.log")))
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		onStartHandlers = nil
+	}()
+
+	onStartHandlers = nil
+	OnStart(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	a.Equal(run(), ExitCodeStartTimeout)
+
+	// 超时的 handler 最多等待 shutdown_timeout。
+	onStartHandlers = nil
+	block := make(chan bool)
+	exited := make(chan bool)
+	OnStart(func(ctx context.Context) error {
+		defer close(exited)
+		<-block
+		return nil
+	})
+	a.Equal(run(), ExitCodeStartTimeout)
+	close(block)
+	<-exited
+
+	// HookTimeout 会覆盖默认值。
+	onStartHandlers = nil
+	OnStart(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	}, HookTimeout(time.Second))
+	a.Equal(run(), ExitCodeOK)
+}
+
+func TestOnStartHandlerNil(t *testing.T) {
+	a := assert.New(t)
+
+	defer func() {
+		onStartHandlers = nil
+	}()
+
+	onStartHandlers = nil
+	var typed func(ctx context.Context, db *testDB) error
+	OnStartHandler(typed)
+	OnStartHandler(nil)
+	OnStart(nil)
+	a.Equal(len(onStartHandlers), 0)
+}
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	"github.com/altstory/go-log"
+)
+
+// PanicInfo 是 handler 发生 panic 时的现场信息。
+type PanicInfo struct {
+	Value  interface{} // Value 是 recover 得到的值。
+	Stack  []byte      // Stack 是发生 panic 的 goroutine 的调用栈。
+	Phase  Phase       // Phase 是 handler 所在的生命周期阶段，无法确定时为空。
+	Caller string      // Caller 是注册 handler 的调用方，无法确定时为空。
+}
+
+// PanicHandler 处理 handler 发生的 panic。
+type PanicHandler func(ctx context.Context, info *PanicInfo)
+
+var panicHandler struct {
+	mu      sync.Mutex
+	handler PanicHandler
+}
+
+// SetPanicHandler 设置 handler 发生 panic 时的处理函数，可以用来把崩溃信息写到文件或者上报给收集服务。
+//
+// runner 会先记录错误日志，再同步调用 handler，之后 handler 所在的阶段按照执行出错来处理。
+// 重复调用会覆盖之前的设置，设置为 nil 表示不再处理 panic。
+func SetPanicHandler(handler PanicHandler) {
+	panicHandler.mu.Lock()
+	defer panicHandler.mu.Unlock()
+	panicHandler.handler = handler
+}
+
+// reportPanic 记录 panic 日志并调用 PanicHandler，info 为 nil 表示不知道 handler 的信息。
+func reportPanic(ctx context.Context, info *HandlerInfo, r interface{}, stack []byte) {
+	p := &PanicInfo{
+		Value: r,
+		Stack: stack,
+	}
+
+	if info != nil {
+		p.Phase = info.Phase
+		p.Caller = info.Caller
+	}
+
+	log.Errorf(ctx, "phase=%v||caller=%v||go-runner: caught a panic: %v\n%v", p.Phase, p.Caller, r, string(stack))
+
+	panicHandler.mu.Lock()
+	handler := panicHandler.handler
+	panicHandler.mu.Unlock()
+
+	if handler == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf(ctx, "go-runner: caught a panic in panic handler: %v\n%v", r, string(debug.Stack()))
+		}
+	}()
+
+	handler(ctx, p)
+}
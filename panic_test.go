@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestPanicHandler(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer func() {
+		resetTestInjection()
+		onExitHandlers = nil
+		SetPanicHandler(nil)
+	}()
+	resetTestInjection()
+	onExitHandlers = nil
+
+	var panics []*PanicInfo
+	SetPanicHandler(func(ctx context.Context, info *PanicInfo) {
+		panics = append(panics, info)
+	})
+
+	exited := false
+	OnExit(func(ctx context.Context) {
+		exited = true
+	})
+	OnExit(func(ctx context.Context) {
+		panic("exit")
+	})
+	AddServer("", func(ctx context.Context) {
+		panic("server")
+	})
+
+	// server 的 panic 会导致服务出错退出，退出函数的 panic 不会阻止其他退出函数执行。
+	a.Equal(run(), ExitCodeHandlerError)
+	a.Assert(exited)
+	a.Equal(len(panics), 2)
+	a.Equal(panics[0].Value, "server")
+	a.Equal(panics[0].Phase, PhaseServer)
+	a.Assert(strings.Contains(panics[0].Caller, "panic_test.go"))
+	a.Assert(len(panics[0].Stack) > 0)
+	a.Equal(panics[1].Value, "exit")
+	a.Equal(panics[1].Phase, PhaseExit)
+	a.Assert(strings.Contains(panics[1].Caller, "panic_test.go"))
+
+	// PanicHandler 自身的 panic 会被忽略。
+	resetTestInjection()
+	onExitHandlers = nil
+	SetPanicHandler(func(ctx context.Context, info *PanicInfo) {
+		panic("panic handler")
+	})
+	OnStart(func(ctx context.Context) error {
+		panic("start")
+	})
+	a.Equal(run(), ExitCodeHandlerError)
+}
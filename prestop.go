@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/altstory/go-log"
+)
+
+// PhasePreStop 表示通过 OnPreStop 注册的 handler。
+const PhasePreStop Phase = "pre_stop"
+
+var onPreStopHandlers []*hook
+
+// OnPreStop 将 handler 注册到 runner 的预停止列表里面。
+//
+// 服务收到 SIGTERM 或者 SIGINT 之后会立即执行这些 handler，此时 server 仍然在正常服务，
+// 适合从服务发现中注销当前实例。
+// 只有注册了 handler 或者设置了 [runner] 中的 drain_delay，runner 才会拦截这些信号。
+// handler 会按照与启动顺序相反的顺序执行，就像 defer 一样，详见 HookOption。
+func OnPreStop(handler func(ctx context.Context), opts ...HookOption) {
+	if handler == nil {
+		return
+	}
+
+	caller := findCaller(1)
+	info := &HandlerInfo{
+		Phase:  PhasePreStop,
+		Caller: caller,
+	}
+	onPreStopHandlers = append(onPreStopHandlers, newHook(intercept(info, func(ctx context.Context) int {
+		handler(ctx)
+		return ExitCodeOK
+	}), caller, opts))
+}
+
+func runPreStopHandlers(ctx context.Context) {
+	hooks, err := sortHooks(onPreStopHandlers)
+
+	if err != nil {
+		log.Errorf(ctx, "err=%v||go-runner: invalid order of pre-stop handlers and fall back to reverse registration order", err)
+		hooks = onPreStopHandlers
+	}
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].Handler.Call(ctx)
+	}
+}
+
+// forceExit 用于在平滑退出失败时强制结束进程，单元测试中可以替换成其他函数。
+var forceExit = os.Exit
+
+// drainOnSignal 在收到停止信号之后开始摘除流量，等待 [runner] 中 drain_delay 之后调用 stop 停止所有 server。
+//
+// 在等待期间服务处于 StateDraining 状态，Ready 返回 false，server 仍然可以继续服务。
+// 收到第一个信号之后，会通过 watchShutdown 监控退出过程，直到 exited 被关闭才返回。
+func drainOnSignal(ctx context.Context, sig chan os.Signal, exited <-chan struct{}, stop func()) {
+	select {
+	case s := <-sig:
+		log.Warnf(ctx, "signal=%v||go-runner: server is draining", s)
+	case <-ctx.Done():
+		return
+	}
+
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		watchShutdown(ctx, sig, exited)
+	}()
+	defer func() {
+		<-watched
+	}()
+	changeState(ctx, func(from LifecycleState) bool {
+		return from == StateRunning
+	}, StateDraining)
+	runPreStopHandlers(ctx)
+
+	if delay := runnerFromContext(ctx).RunnerConfig.DrainDelay; delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	stop()
+}
+
+// watchShutdown 在服务开始平滑退出之后强制结束进程，如果服务再次收到停止信号，
+// 或者在 [runner] 中 drain_delay 与 shutdown_timeout 之和的时间内还没有退出完毕。
+// shutdown_timeout 为 0 时不限制退出时间，只有再次收到信号才会强制退出。
+func watchShutdown(ctx context.Context, sig <-chan os.Signal, exited <-chan struct{}) {
+	rc := runnerFromContext(ctx).RunnerConfig
+	timeout := rc.DrainDelay + rc.ShutdownTimeout
+	var expired <-chan time.Time
+
+	if rc.ShutdownTimeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-exited:
+		return
+	case s := <-sig:
+		log.Errorf(ctx, "signal=%v||go-runner: server is forced to exit by another signal", s)
+	case <-expired:
+		log.Errorf(ctx, "timeout=%v||go-runner: server does not exit in time and is forced to exit", timeout)
+	}
+
+	log.Flush()
+	forceExit(ExitCodeForcedExit)
+}
@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestDrainDelay(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[runner]\ndrain_delay = \"50ms\"\n", filepath.Join(dir, "log", "test.log")))
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		resetTestInjection()
+	}()
+	resetTestInjection()
+
+	var calls []string
+	var readyRunning, readyDraining bool
+	var signaled, stopped time.Time
+
+	OnPreStop(func(ctx context.Context) {
+		calls = append(calls, "first")
+	})
+	OnPreStop(func(ctx context.Context) {
+		calls = append(calls, "second")
+		readyDraining = Ready()
+		a.Equal(State(), StateDraining)
+	})
+	AddServer("", func(ctx context.Context) {
+		readyRunning = Ready()
+		signaled = time.Now()
+		a.NilError(syscall.Kill(os.Getpid(), syscall.SIGTERM))
+
+		// 在等待流量摘除期间，server 仍然在运行。
+		<-ctx.Done()
+		stopped = time.Now()
+	})
+
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(calls, []string{"second", "first"})
+	a.Assert(readyRunning)
+	a.Assert(!readyDraining)
+	a.Assert(stopped.Sub(signaled) >= 50*time.Millisecond)
+}
+
+func TestForcedExit(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	logPath := filepath.Join(dir, "log", "test.log")
+
+	old := *flagConfig
+	oldExit := forceExit
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		forceExit = oldExit
+		resetTestInjection()
+	}()
+
+	var forced int
+	release := make(chan bool)
+	forceExit = func(code int) {
+		forced = code
+		close(release)
+	}
+	test := func(config string, signals int) {
+		resetTestInjection()
+		forced = ExitCodeOK
+		release = make(chan bool)
+		writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[runner]\n%v", logPath, config))
+
+		AddServer("", func(ctx context.Context) {
+			a.NilError(syscall.Kill(os.Getpid(), syscall.SIGTERM))
+
+			for i := 1; i < signals; i++ {
+				for State() != StateDraining {
+					time.Sleep(time.Millisecond)
+				}
+
+				a.NilError(syscall.Kill(os.Getpid(), syscall.SIGTERM))
+			}
+
+			// server 忽略 ctx，直到被强制退出。
+			<-release
+		})
+		a.Equal(run(), ExitCodeOK)
+		a.Equal(forced, ExitCodeForcedExit)
+	}
+
+	// 超过 drain_delay 与 shutdown_timeout 之和之后强制退出。
+	test("drain_delay = \"10ms\"\nshutdown_timeout = \"20ms\"\n", 1)
+
+	// 再次收到信号之后强制退出。
+	test("drain_delay = \"1h\"\nshutdown_timeout = \"0s\"\n", 2)
+}
@@ -0,0 +1,493 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/altstory/go-config"
+)
+
+const (
+	configFormatTOML = "toml"
+	configFormatJSON = "json"
+
+	// defaultLayer 表示字段的值来自配置结构的默认值。
+	defaultLayer = "<default>"
+
+	// deletesKey 是额外配置文件中用来删除字段的特殊字段，与 go-config 保持一致。
+	deletesKey = "_deletes"
+)
+
+// configDump 是合并之后的完整配置，以及每个字段来自哪一层配置。
+type configDump struct {
+	Config map[string]interface{} `json:"config"`           // Config 是合并后的配置，敏感字段已经被隐藏。
+	Layers map[string]string      `json:"layers"`           // Layers 记录每个字段的完整路径来自哪个配置文件。
+	Errors []string               `json:"errors,omitempty"` // Errors 是配置中的所有错误，例如校验失败或者无法解析的密钥引用。
+}
+
+// printConfig 加载所有配置文件并将合并后的配置以 format 格式输出到 w。
+func printConfig(w io.Writer, format string) int {
+	runner := &runnerContext{}
+	ctx := context.WithValue(context.Background(), keyRunnerContext, runner)
+	path := *flagConfig
+	c, files, err := loadConfigFiles(ctx, path)
+
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return ExitCodeInvalidConfig
+	}
+
+	dump, err := dumpConfig(c, files, configBindings)
+
+	if err != nil {
+		fmt.Fprintf(w, "go-runner: fail to dump config: %v\n", err)
+		return ExitCodeInvalidConfig
+	}
+
+	switch format {
+	case configFormatTOML:
+		writeConfigTOML(w, nil, dump.Config, dump.Layers)
+
+		// 以注释的形式输出错误，保证输出依然是合法的 TOML。
+		if len(dump.Errors) > 0 {
+			fmt.Fprintln(w, "\n# go-runner: invalid config:")
+
+			for _, e := range dump.Errors {
+				fmt.Fprintf(w, "#     %v\n", e)
+			}
+		}
+
+	case configFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		enc.Encode(dump)
+
+	default:
+		fmt.Fprintf(w, "go-runner: unsupported config format %v\n", format)
+		return ExitCodeInvalidConfig
+	}
+
+	if len(dump.Errors) > 0 {
+		return ExitCodeInvalidConfig
+	}
+
+	return ExitCodeOK
+}
+
+// dumpConfig 返回 c 中合并后的配置，files 是按加载顺序排列的配置文件。
+//
+// 配置文件中不存在、但是在 bindings 中设置了默认值的字段也会出现在结果里。
+// 所有敏感字段的值都会被替换成 redactedValue，密钥引用不会被解析。
+//
+// 配置不合法时依然会返回完整的配置，所有的错误都记录在 Errors 里，方便排查问题。
+func dumpConfig(c *config.Config, files []*configFile, bindings []*configBinding) (*configDump, error) {
+	var raw interface{}
+
+	if err := c.Unmarshal("", &raw); err != nil {
+		return nil, err
+	}
+
+	m, _ := normalizeConfig(reflect.ValueOf(raw)).(map[string]interface{})
+
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+
+	origins, err := configOrigins(files)
+
+	if err != nil {
+		return nil, err
+	}
+
+	all := append(builtinConfigBindings(), bindings...)
+	var errs []string
+
+	for _, binding := range all {
+		if binding.Path != "" {
+			continue
+		}
+
+		v, err := binding.Decode(c)
+
+		if err != nil {
+			errs = appendConfigErrors(errs, binding.Section, err)
+			continue
+		}
+
+		if defaults := normalizeConfig(v); defaults != nil {
+			fillConfigDefaults(m, binding.Section, defaults, origins)
+		}
+
+		if err := verifyConfig(binding.Section, v.Interface()); err != nil {
+			errs = appendConfigErrors(errs, binding.Section, err)
+		}
+	}
+
+	redactConfig(secretConfigKeys(all), "", m)
+	return &configDump{
+		Config: m,
+		Layers: origins,
+		Errors: errs,
+	}, nil
+}
+
+func appendConfigErrors(errs []string, section string, err error) []string {
+	if e, ok := err.(validationErrors); ok {
+		for _, ve := range e {
+			errs = append(errs, ve.Error())
+		}
+
+		return errs
+	}
+
+	return append(errs, fmt.Sprintf("%v: %v", section, err))
+}
+
+// configOrigins 计算每个字段最终来自 files 中的哪个配置文件。
+func configOrigins(files []*configFile) (map[string]string, error) {
+	origins := map[string]string{}
+
+	for _, f := range files {
+		c, err := f.Open()
+
+		if err != nil {
+			return nil, err
+		}
+
+		var raw map[string]interface{}
+
+		if err := c.Unmarshal("", &raw); err != nil {
+			return nil, err
+		}
+
+		var deletes []string
+
+		if err := c.Unmarshal(deletesKey, &deletes); err != nil {
+			return nil, err
+		}
+
+		delete(raw, deletesKey)
+
+		for _, del := range deletes {
+			for key := range origins {
+				if key == del || strings.HasPrefix(key, del+".") {
+					delete(origins, key)
+				}
+			}
+		}
+
+		var keys []string
+		collectConfigKeys(&keys, "", reflect.ValueOf(raw))
+
+		for _, key := range keys {
+			origins[key] = f.Name
+		}
+	}
+
+	return origins, nil
+}
+
+// fillConfigDefaults 将 section 中 resolved 有、但是 m 中没有的非零值字段补充到 m 里。
+func fillConfigDefaults(m map[string]interface{}, section string, resolved interface{}, origins map[string]string) {
+	if section == "" {
+		if table, ok := resolved.(map[string]interface{}); ok {
+			fillConfigTable(m, "", table, origins)
+		}
+
+		return
+	}
+
+	parts := strings.Split(section, ".")
+	last := len(parts) - 1
+	table := m
+
+	for _, part := range parts[:last] {
+		sub, ok := table[part].(map[string]interface{})
+
+		if !ok {
+			if _, exists := table[part]; exists {
+				return
+			}
+
+			sub = map[string]interface{}{}
+			table[part] = sub
+		}
+
+		table = sub
+	}
+
+	fillConfigTable(table, strings.Join(parts[:last], "."), map[string]interface{}{
+		parts[last]: resolved,
+	}, origins)
+}
+
+func fillConfigTable(dst map[string]interface{}, path string, src map[string]interface{}, origins map[string]string) {
+	for k, v := range src {
+		key := joinConfigPath(path, k)
+		table, isTable := v.(map[string]interface{})
+		existing, exists := dst[k]
+
+		if !exists {
+			if isTable {
+				sub := map[string]interface{}{}
+				fillConfigTable(sub, key, table, origins)
+
+				if len(sub) > 0 {
+					dst[k] = sub
+				}
+
+				continue
+			}
+
+			if v == nil || isEmptyValue(reflect.ValueOf(v)) {
+				continue
+			}
+
+			dst[k] = v
+			origins[key] = defaultLayer
+			continue
+		}
+
+		if sub, ok := existing.(map[string]interface{}); ok && isTable {
+			fillConfigTable(sub, key, table, origins)
+		}
+	}
+}
+
+// normalizeConfig 将配置数据或者配置结构转化成只包含 map[string]interface{}、[]interface{} 和基本类型的数据。
+func normalizeConfig(v reflect.Value) interface{} {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Type() {
+	case typeOfDuration:
+		return time.Duration(v.Int()).String()
+	case typeOfTime:
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+
+		for _, key := range v.MapKeys() {
+			m[fmt.Sprint(key.Interface())] = normalizeConfig(v.MapIndex(key))
+		}
+
+		return m
+
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, 0, v.Len())
+
+		for i := 0; i < v.Len(); i++ {
+			list = append(list, normalizeConfig(v.Index(i)))
+		}
+
+		return list
+
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		normalizeStruct(m, v)
+		return m
+	}
+
+	return v.Interface()
+}
+
+func normalizeStruct(m map[string]interface{}, v reflect.Value) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, squash, skipped := parseConfigTag(field)
+
+		if skipped {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		// 零值字段对于补充默认值没有意义，直接跳过。
+		if isEmptyValue(fv) {
+			continue
+		}
+
+		if squash {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+
+			if fv.Kind() == reflect.Struct {
+				normalizeStruct(m, fv)
+			}
+
+			continue
+		}
+
+		m[name] = normalizeConfig(fv)
+	}
+}
+
+var bareTOMLKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// writeConfigTOML 将 m 以 TOML 格式输出到 w，每个字段后面都会用注释标明这个字段来自哪个配置文件。
+func writeConfigTOML(w io.Writer, path []string, m map[string]interface{}, origins map[string]string) {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	var tables, arrays []string
+
+	for _, k := range keys {
+		v := m[k]
+
+		if v == nil {
+			continue
+		}
+
+		if _, ok := v.(map[string]interface{}); ok {
+			tables = append(tables, k)
+			continue
+		}
+
+		if isTOMLArrayOfTables(v) {
+			arrays = append(arrays, k)
+			continue
+		}
+
+		fmt.Fprintf(w, "%v = %v", formatTOMLKey(k), formatTOMLValue(v))
+
+		if origin := origins[strings.Join(append(path, k), ".")]; origin != "" {
+			fmt.Fprintf(w, "  # %v", origin)
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	for _, k := range tables {
+		sub := append(append([]string{}, path...), k)
+		fmt.Fprintf(w, "\n[%v]\n", formatTOMLPath(sub))
+		writeConfigTOML(w, sub, m[k].(map[string]interface{}), origins)
+	}
+
+	for _, k := range arrays {
+		sub := append(append([]string{}, path...), k)
+
+		for _, elem := range m[k].([]interface{}) {
+			fmt.Fprintf(w, "\n[[%v]]\n", formatTOMLPath(sub))
+			writeConfigTOML(w, sub, elem.(map[string]interface{}), origins)
+		}
+	}
+}
+
+func isTOMLArrayOfTables(v interface{}) bool {
+	list, ok := v.([]interface{})
+
+	if !ok || len(list) == 0 {
+		return false
+	}
+
+	for _, elem := range list {
+		if _, ok := elem.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatTOMLKey(key string) string {
+	if bareTOMLKey.MatchString(key) {
+		return key
+	}
+
+	return strconv.Quote(key)
+}
+
+func formatTOMLPath(path []string) string {
+	keys := make([]string, 0, len(path))
+
+	for _, key := range path {
+		keys = append(keys, formatTOMLKey(key))
+	}
+
+	return strings.Join(keys, ".")
+}
+
+func formatTOMLValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return strconv.Quote(val)
+
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+
+	case float32:
+		return formatTOMLFloat(float64(val))
+
+	case float64:
+		return formatTOMLFloat(val)
+
+	case []interface{}:
+		elems := make([]string, 0, len(val))
+
+		for _, elem := range val {
+			elems = append(elems, formatTOMLValue(elem))
+		}
+
+		return "[" + strings.Join(elems, ", ") + "]"
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+
+		for k := range val {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys))
+
+		for _, k := range keys {
+			pairs = append(pairs, formatTOMLKey(k)+" = "+formatTOMLValue(val[k]))
+		}
+
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+
+	return fmt.Sprint(v)
+}
+
+func formatTOMLFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+
+	return s
+}
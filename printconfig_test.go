@@ -0,0 +1,176 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+type testPrintConfig struct {
+	Addr     string        `config:"addr"`
+	Timeout  time.Duration `config:"timeout" default:"3s"`
+	Password string        `config:"password"`
+	Key      string        `config:"key" secret:"true"`
+}
+
+func TestPrintConfig(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "service.conf")
+	extPath := filepath.Join(dir, "ext.conf")
+	writeTestFile(a, confPath, `[server]
+addr = ":8080"
+password = "123456"
+key = "abcdef"
+debug = true
+
+[[server.backends]]
+addr = "10.0.0.1:80"
+`)
+	writeTestFile(a, extPath, `_deletes = ['server.debug']
+
+[server]
+addr = ":9090"
+`)
+
+	a.NilError(os.Setenv(envRunnerExtConfig, extPath))
+	defer os.Unsetenv(envRunnerExtConfig)
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		configHandlers = nil
+		configBindings = nil
+		serverHandlers = nil
+	}()
+
+	configHandlers = nil
+	configBindings = nil
+	serverHandlers = nil
+	AddServer("server", func(ctx context.Context, c *testPrintConfig) {})
+
+	buf := &bytes.Buffer{}
+	a.Equal(printConfig(buf, configFormatTOML), ExitCodeOK)
+	a.Equal(buf.String(), `
+[runner]
+config_history = 20  # <default>
+shutdown_timeout = "10s"  # <default>
+
+[server]
+addr = ":9090"  # `+extPath+`
+key = "******"  # `+confPath+`
+password = "******"  # `+confPath+`
+timeout = "3s"  # <default>
+
+[[server.backends]]
+addr = "10.0.0.1:80"  # `+confPath+`
+`)
+
+	buf.Reset()
+	a.Equal(printConfig(buf, configFormatJSON), ExitCodeOK)
+
+	var dump configDump
+	a.NilError(json.Unmarshal(buf.Bytes(), &dump))
+	a.Equal(dump.Config["server"].(map[string]interface{})["addr"], ":9090")
+	a.Equal(dump.Layers["server.addr"], extPath)
+	a.Equal(dump.Layers["server.timeout"], defaultLayer)
+	a.Assert(!strings.Contains(buf.String(), "123456"))
+	a.Assert(!strings.Contains(buf.String(), "abcdef"))
+
+	buf.Reset()
+	a.Equal(printConfig(buf, "yaml"), ExitCodeInvalidConfig)
+}
+
+type testPrintInvalidConfig struct {
+	Addr  string `config:"addr" validate:"required"`
+	Token string `config:"token" secret:"true"`
+	Mode  string `config:"mode" default:"release"`
+}
+
+func TestPrintInvalidConfig(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "service.conf")
+	writeTestFile(a, confPath, `[server]
+token = "${env:GO_RUNNER_TEST_NOT_SET}"
+`)
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		configHandlers = nil
+		configBindings = nil
+		serverHandlers = nil
+	}()
+
+	configHandlers = nil
+	configBindings = nil
+	serverHandlers = nil
+	AddServer("server", func(ctx context.Context, c *testPrintInvalidConfig) {})
+
+	// 配置不合法、密钥无法解析时，依然会输出完整的配置以及所有错误。
+	buf := &bytes.Buffer{}
+	a.Equal(printConfig(buf, configFormatTOML), ExitCodeInvalidConfig)
+	a.Equal(buf.String(), `
+[runner]
+config_history = 20  # <default>
+shutdown_timeout = "10s"  # <default>
+
+[server]
+mode = "release"  # <default>
+token = "******"  # `+confPath+`
+
+# go-runner: invalid config:
+#     server.token: fail to resolve secret ${env:GO_RUNNER_TEST_NOT_SET}: environment variable GO_RUNNER_TEST_NOT_SET is not set
+`)
+
+	buf.Reset()
+	a.Equal(printConfig(buf, configFormatJSON), ExitCodeInvalidConfig)
+
+	var dump configDump
+	a.NilError(json.Unmarshal(buf.Bytes(), &dump))
+	a.Equal(dump.Config["server"].(map[string]interface{})["mode"], "release")
+	a.Equal(len(dump.Errors), 1)
+
+	// section 中缺少必填字段。
+	writeTestFile(a, confPath, "[server]\nmode = \"debug\"\n")
+	buf.Reset()
+	a.Equal(printConfig(buf, configFormatTOML), ExitCodeInvalidConfig)
+	a.Assert(strings.Contains(buf.String(), "mode = \"debug\""))
+	a.Assert(strings.Contains(buf.String(), "#     server.addr: "))
+}
+
+func TestSecretConfigKeysRecursive(t *testing.T) {
+	a := assert.New(t)
+
+	// 递归引用自身的配置中，子节点整个被当做敏感字段。
+	secrets := secretConfigKeys([]*configBinding{
+		{
+			Section: "tree",
+			Type:    reflect.TypeOf(&testConfigNode{}),
+		},
+	})
+	a.Equal(secrets, map[string]bool{
+		"tree.password": true,
+		"tree.children": true,
+	})
+}
@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"reflect"
+	"strings"
+)
+
+const (
+	secretTagName = "secret"
+
+	redactedValue = "******"
+)
+
+// secretKeyPatterns 是敏感字段名的特征，字段名中只要包含任意一个特征就会被当做敏感字段。
+var secretKeyPatterns = []string{"password", "passwd", "token", "secret"}
+
+// secretConfigKeys 返回 bindings 中所有标记了 `secret:"true"` 的字段路径。
+func secretConfigKeys(bindings []*configBinding) map[string]bool {
+	secrets := map[string]bool{}
+
+	for _, binding := range bindings {
+		if binding.Path != "" {
+			continue
+		}
+
+		collectSecretKeys(secrets, binding.Section, binding.Type)
+	}
+
+	return secrets
+}
+
+// secretRecursion 记录递归引用自身的 struct 类型再次出现的位置。
+type secretRecursion struct {
+	Path  string // Path 是类型再次出现的路径。
+	First string // First 是类型第一次出现的路径。
+}
+
+// collectSecretKeys 将 t 类型在 path 下所有标记了 `secret:"true"` 的字段路径记录到 secrets 里面。
+//
+// 递归引用自身的 struct 无法逐个字段展开，如果这个类型包含敏感字段，
+// 那么再次遇到这个类型时，整个路径都会被当做敏感字段，宁可多隐藏一些内容也不能泄露密钥。
+func collectSecretKeys(secrets map[string]bool, path string, t reflect.Type) {
+	var recursions []*secretRecursion
+	keys := map[string]bool{}
+	collectTypeSecretKeys(keys, map[reflect.Type]string{}, &recursions, path, t)
+
+	for _, r := range recursions {
+		prefix := r.First + "."
+
+		for key := range keys {
+			if r.First == "" || strings.HasPrefix(key, prefix) {
+				secrets[r.Path] = true
+				break
+			}
+		}
+	}
+
+	for key := range keys {
+		secrets[key] = true
+	}
+}
+
+// collectTypeSecretKeys 是 collectSecretKeys 的实现，visiting 记录了正在展开的 struct 类型第一次出现的路径。
+func collectTypeSecretKeys(secrets map[string]bool, visiting map[reflect.Type]string, recursions *[]*secretRecursion, path string, t reflect.Type) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || t == typeOfTime {
+		return
+	}
+
+	if first, ok := visiting[t]; ok {
+		*recursions = append(*recursions, &secretRecursion{
+			Path:  path,
+			First: first,
+		})
+		return
+	}
+
+	visiting[t] = path
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, squash, skipped := parseConfigTag(field)
+
+		if skipped {
+			continue
+		}
+
+		fieldPath := path
+
+		if !squash {
+			fieldPath = joinConfigPath(path, name)
+		}
+
+		if field.Tag.Get(secretTagName) == "true" {
+			secrets[fieldPath] = true
+			continue
+		}
+
+		collectTypeSecretKeys(secrets, visiting, recursions, fieldPath, field.Type)
+	}
+}
+
+// isSecretConfigKey 判断 key 是否是敏感字段，key 是字段的完整路径。
+func isSecretConfigKey(secrets map[string]bool, key string) bool {
+	if secrets[key] {
+		return true
+	}
+
+	name := strings.ToLower(key)
+
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+
+	for _, pattern := range secretKeyPatterns {
+		if strings.Contains(name, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactConfig 将 m 中所有敏感字段的值替换成 redactedValue，m 必须是 normalizeConfig 返回的数据。
+func redactConfig(secrets map[string]bool, path string, m map[string]interface{}) {
+	for k, v := range m {
+		key := joinConfigPath(path, k)
+
+		if isSecretConfigKey(secrets, key) {
+			m[k] = redactedValue
+			continue
+		}
+
+		switch val := v.(type) {
+		case map[string]interface{}:
+			redactConfig(secrets, key, val)
+
+		case []interface{}:
+			for _, elem := range val {
+				if table, ok := elem.(map[string]interface{}); ok {
+					redactConfig(secrets, key, table)
+				}
+			}
+		}
+	}
+}
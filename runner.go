@@ -9,7 +9,9 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/altstory/go-config"
 	"github.com/altstory/go-log"
@@ -27,11 +29,27 @@ const (
 
 	// ExitCodeHandlerError 是 handler 执行出错或者 panic 返回的错误码。
 	ExitCodeHandlerError
+
+	// ExitCodeStartTimeout 是 OnStart 注册的 handler 执行超时返回的错误码。
+	ExitCodeStartTimeout
+
+	// ExitCodeForcedExit 是服务在平滑退出过程中超时，或者再次收到停止信号而被强制退出的错误码。
+	ExitCodeForcedExit
 )
 
 var (
 	flagConfig  = flag.String("config", "./conf/service.conf", "Set config file for this server.")
 	flagVersion = flag.Bool("version", false, "Display version of this server.")
+
+	flagStrictConfig = flag.Bool("strict-config", false, "Reject unknown keys in config file.")
+	flagCheckConfig  = flag.Bool("check-config", false, "Check config file and exit without starting any client or server.")
+
+	flagPrintConfig       = flag.Bool("print-config", false, "Print merged config with secrets redacted and exit.")
+	flagPrintConfigFormat = flag.String("print-config-format", configFormatTOML, "Set the format of -print-config. Supported formats are toml and json.")
+	flagDumpConfigSchema  = flag.Bool("dump-config-schema", false, "Dump JSON Schema of all registered config sections and exit.")
+
+	flagConfigSource         = flag.String("config-source", "", "Set the URL of an extra config source, e.g. file:///path/to/conf.d or http://host/service.conf.")
+	flagConfigSourceInterval = flag.Duration("config-source-interval", defaultConfigSourceInterval, "Set the interval to poll changes of config source.")
 )
 
 type keyRunnerContextType struct{}
@@ -41,7 +59,43 @@ var (
 )
 
 type runnerContext struct {
-	Config *config.Config
+	Config       *config.Config
+	ConfigPath   string
+	RunnerConfig runnerConfig
+	Source       ConfigSource
+	Dependencies map[reflect.Type]reflect.Value // Dependencies 保存所有 client 提供的值。
+	Startup      *StartupReport                 // Startup 是正在统计的启动耗时报告，启动完成后为 nil。
+
+	mu sync.RWMutex
+}
+
+// CurrentConfig 返回当前生效的配置，配置可能会在服务运行过程中被 ConfigSource 重新加载。
+func (runner *runnerContext) CurrentConfig() *config.Config {
+	runner.mu.RLock()
+	defer runner.mu.RUnlock()
+	return runner.Config
+}
+
+// SetConfig 替换当前生效的配置。
+func (runner *runnerContext) SetConfig(c *config.Config) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	runner.Config = c
+}
+
+const (
+	logSection    = "log"
+	runnerSection = "runner"
+)
+
+// runnerConfig 是 runner 自身的配置，对应配置文件中的 [runner]。
+type runnerConfig struct {
+	StrictConfig    bool          `config:"strict_config"`                                    // StrictConfig 为 true 时，配置中任何未被读取的字段都会导致启动失败。
+	ConfigHistory   int           `config:"config_history" default:"20" validate:"min=0"`     // ConfigHistory 是最多保留的配置变更记录条数。
+	StartTimeout    time.Duration `config:"start_timeout" validate:"min=0s"`                  // StartTimeout 是 OnStart 注册的 handler 的默认超时时间，0 表示不超时。
+	FailOnExitError bool          `config:"fail_on_exit_error"`                               // FailOnExitError 为 true 时，OnExitE 注册的 handler 返回错误会导致服务以非 0 错误码退出。
+	DrainDelay      time.Duration `config:"drain_delay" validate:"min=0s"`                    // DrainDelay 是收到停止信号之后、停止 server 之前等待流量摘除的时间。
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" default:"10s" validate:"min=0s"` // ShutdownTimeout 是服务退出时等待后台任务和定时任务结束的最长时间，0 表示一直等待。
 }
 
 // Main 是整个框架的启动入口，这个函数永远不会返回。
@@ -57,41 +111,51 @@ func Main() {
 		return
 	}
 
+	if *flagCheckConfig {
+		os.Exit(checkConfig(os.Stdout))
+	}
+
+	if *flagPrintConfig {
+		os.Exit(printConfig(os.Stdout, *flagPrintConfigFormat))
+	}
+
+	if *flagDumpConfigSchema {
+		os.Exit(dumpConfigSchema(os.Stdout))
+	}
+
 	os.Exit(run())
 	panic("never reach here")
 }
 
-const envRunnerExtConfig = "ALTSTORY_RUNNER_EXT_CONFIG"
-
 func run() (code int) {
-	runner := &runnerContext{}
+	runner := &runnerContext{
+		Startup: &StartupReport{
+			StartTime: time.Now(),
+		},
+	}
 	ctx := context.WithValue(context.Background(), keyRunnerContext, runner)
+	defer resetTasks()
+	setState(ctx, StateInitializing)
+	defer func() {
+		if code != ExitCodeOK {
+			setState(ctx, StateFailed)
+		}
+	}()
 
 	// 先自动初始化日志。
 	path := *flagConfig
-	c, err := config.LoadFile(path)
+	c, files, err := loadConfigFiles(ctx, path)
 
 	if err != nil {
-		log.Errorf(ctx, "err=%v||config=%v||go-runner: fail to parse config file", err, path)
+		log.Errorf(ctx, "err=%v||go-runner: fail to load config", err)
 		return ExitCodeInvalidConfig
 	}
 
-	// 如果有环境变量设置了额外追加的配置文件，加载这个配置文件。
-	extPath, exists := os.LookupEnv(envRunnerExtConfig)
-
-	if exists && extPath != "" {
-		err = c.LoadExt(extPath)
-
-		if err != nil {
-			log.Errorf(ctx, "err=%v||config=%v||ext_config=%v||go-runner: fail to parse extension config file", err, path, extPath)
-			return ExitCodeInvalidConfig
-		}
-	}
-
 	runner.Config = c
+	runner.ConfigPath = path
 	var logConfig log.Config
 
-	if err := runner.Config.Unmarshal("log", &logConfig); err != nil {
+	if err := runner.Config.Unmarshal(logSection, &logConfig); err != nil {
 		log.Errorf(ctx, "err=%v||go-runner: fail to read config", err)
 		return ExitCodeInvalidConfig
 	}
@@ -103,6 +167,26 @@ func run() (code int) {
 		log.Warnf(ctx, "code=%v||go-runner: server is exiting", code)
 		log.Flush()
 	}()
+	defer func() {
+		if code == ExitCodeOK {
+			setState(ctx, StateStopped)
+		} else {
+			setState(ctx, StateFailed)
+		}
+	}()
+
+	log.Infof(ctx, "config_files=%v||go-runner: config files are loaded in order", files)
+
+	if code = loadRunnerConfig(ctx); code != ExitCodeOK {
+		return
+	}
+
+	// 记录额外配置文件对主配置文件的修改。
+	if len(files) > 1 {
+		if base, err := files[0].Open(); err == nil {
+			recordConfigChanges(ctx, base, runner.Config, files)
+		}
+	}
 
 	// 配置日志切分。
 	sig := make(chan os.Signal, 1)
@@ -117,7 +201,36 @@ func run() (code int) {
 		close(sig)
 	}()
 
-	defer runExitHandlers(ctx)
+	// exited 在所有退出函数执行完毕之后关闭，用于结束平滑退出的超时监控，
+	// drained 在拦截了停止信号时使用，等待平滑退出的 goroutine 结束。
+	exited := make(chan struct{})
+	var drained chan struct{}
+	defer func() {
+		close(exited)
+
+		if drained != nil {
+			<-drained
+		}
+	}()
+
+	defer func() {
+		setState(ctx, StateStopping)
+		stopTasks(ctx)
+
+		if c := runExitHandlers(ctx); c != ExitCodeOK && code == ExitCodeOK && runner.RunnerConfig.FailOnExitError {
+			code = c
+		}
+	}()
+
+	if code = checkConfigs(ctx, runner.Config); code != ExitCodeOK {
+		return
+	}
+
+	if code = checkJobs(ctx); code != ExitCodeOK {
+		return
+	}
+
+	setState(ctx, StateConfiguringClients)
 
 	if code = runConfigHandlers(ctx); code != ExitCodeOK {
 		return
@@ -127,14 +240,65 @@ func run() (code int) {
 		return
 	}
 
+	setState(ctx, StateStarting)
+
 	if code = runStartHandlers(ctx); code != ExitCodeOK {
 		return
 	}
 
-	code = runServers(ctx)
+	// 在服务运行过程中监听配置来源的变化。
+	// 退出前需要等待监听结束，避免 OnExit 执行时配置还在被替换。
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	watched := make(chan struct{})
+
+	go func() {
+		defer close(watched)
+		watchConfigSource(watchCtx)
+	}()
+
+	defer func() {
+		stopWatching()
+		<-watched
+	}()
+
+	// 只有需要摘除流量时才拦截停止信号，收到信号之后等待流量摘除再停止所有 server。
+	serverCtx, stopServers := context.WithCancel(ctx)
+	defer stopServers()
+
+	if runner.RunnerConfig.DrainDelay > 0 || len(onPreStopHandlers) > 0 {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+		defer signal.Stop(stop)
+		drained = make(chan struct{})
+		go func() {
+			defer close(drained)
+			drainOnSignal(serverCtx, stop, exited, stopServers)
+		}()
+	}
+
+	finishStartup(ctx)
+	setState(ctx, StateRunning)
+
+	code = runServers(serverCtx)
 	return
 }
 
+// loadRunnerConfig 读取 [runner] 配置，并使用命令行参数覆盖相应的配置。
+func loadRunnerConfig(ctx context.Context) int {
+	runner := runnerFromContext(ctx)
+
+	if err := unmarshalConfig(runner.Config, runnerSection, &runner.RunnerConfig); err != nil {
+		log.Errorf(ctx, "err=%v||go-runner: fail to read runner config", err)
+		return ExitCodeInvalidConfig
+	}
+
+	if *flagStrictConfig {
+		runner.RunnerConfig.StrictConfig = true
+	}
+
+	return ExitCodeOK
+}
+
 // updatePackagePrefix 在调用栈中找到第一个跟当前 PkgPath 不一样的包路径作为包前缀信息，
 // 这样可以简化日志里面的调用栈信息，在不损失信息量的前提下缩减日志文件体积。
 func updatePackagePrefix(c *log.Config) {
@@ -96,6 +96,7 @@ func TestRunWithExtConfig(t *testing.T) {
 	a.NilError(os.Chdir("./internal/testdata"))
 
 	a.NilError(os.Setenv(envRunnerExtConfig, "./conf/service-ext.conf"))
+	defer os.Unsetenv(envRunnerExtConfig)
 	AddClient("", func(ctx context.Context, c *testConfig) {
 		a.Equal(c, &testConfig{
 			Log: log.Config{
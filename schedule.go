@@ -0,0 +1,211 @@
+package runner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule 计算定时任务的下一次执行时间。
+type schedule interface {
+	// Next 返回 t 之后的下一次执行时间，如果永远不会执行则返回零值。
+	Next(t time.Time) time.Time
+}
+
+// everySchedule 每隔固定的时间执行一次。
+type everySchedule struct {
+	Interval time.Duration
+}
+
+func (s *everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.Interval)
+}
+
+// cronSchedule 是一个 cron 表达式，每个字段用一个位图表示所有允许的值。
+type cronSchedule struct {
+	Minute, Hour, Day, Month, Weekday uint64
+
+	// AnyDay 和 AnyWeekday 表示对应字段是否是 *。
+	// 按照 cron 的惯例，如果两个字段都不是 *，那么满足其中任何一个即可。
+	AnyDay, AnyWeekday bool
+}
+
+type cronField struct {
+	Name     string
+	Min, Max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseSchedule 解析 spec，支持标准的 5 个字段的 cron 表达式、@daily 等预定义表达式以及 @every <duration>。
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasPrefix(spec, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+
+		if err != nil {
+			return nil, fmt.Errorf("go-runner: invalid schedule %q: %v", spec, err)
+		}
+
+		if interval <= 0 {
+			return nil, fmt.Errorf("go-runner: invalid schedule %q: interval must be positive", spec)
+		}
+
+		return &everySchedule{
+			Interval: interval,
+		}, nil
+	}
+
+	expr := spec
+
+	if descriptor, ok := cronDescriptors[spec]; ok {
+		expr = descriptor
+	}
+
+	fields := strings.Fields(expr)
+
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("go-runner: invalid schedule %q: cron expression must have %v fields", spec, len(cronFields))
+	}
+
+	bits := make([]uint64, len(fields))
+
+	for i, field := range fields {
+		b, err := parseCronField(field, cronFields[i])
+
+		if err != nil {
+			return nil, fmt.Errorf("go-runner: invalid schedule %q: %v", spec, err)
+		}
+
+		bits[i] = b
+	}
+
+	// 星期中的 7 和 0 都表示星期日。
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &cronSchedule{
+		Minute:     bits[0],
+		Hour:       bits[1],
+		Day:        bits[2],
+		Month:      bits[3],
+		Weekday:    bits[4],
+		AnyDay:     fields[2] == "*",
+		AnyWeekday: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField 解析 cron 表达式的一个字段，支持 *、a、a-b、*/n、a-b/n 以及用逗号分隔的列表。
+func parseCronField(field string, f cronField) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %v field %q", f.Name, part)
+			}
+
+			rng, step = part[:i], n
+		}
+
+		min, max := f.Min, f.Max
+
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+
+			if min, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value in %v field %q", f.Name, part)
+			}
+
+			max = min
+
+			if len(bounds) == 2 {
+				if max, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value in %v field %q", f.Name, part)
+				}
+			} else if step > 1 {
+				// a/n 表示从 a 开始到最大值，每隔 n 执行一次。
+				max = f.Max
+			}
+
+			if min < f.Min || max > f.Max || min > max {
+				return 0, fmt.Errorf("%v field %q is out of range [%v, %v]", f.Name, part, f.Min, f.Max)
+			}
+		}
+
+		for v := min; v <= max; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	// cron 表达式的精度是分钟，从下一分钟开始查找。
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// 最多查找 5 年，如果还找不到则认为永远不会执行，例如 2 月 30 日。
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.Month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.Hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.Minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	day := s.Day&(1<<uint(t.Day())) != 0
+	weekday := s.Weekday&(1<<uint(t.Weekday())) != 0
+
+	if s.AnyDay || s.AnyWeekday {
+		return day && weekday
+	}
+
+	return day || weekday
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	a := assert.New(t)
+	now := time.Date(2020, 1, 31, 10, 30, 15, 0, time.UTC)
+	cases := []struct {
+		Spec string
+		Next time.Time
+	}{
+		{"@every 90s", now.Add(90 * time.Second)},
+		{"* * * * *", time.Date(2020, 1, 31, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, 1, 31, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2020, 1, 31, 13, 0, 0, 0, time.UTC)},
+		{"5,10 8 * * *", time.Date(2020, 2, 1, 8, 5, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2020, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+
+		// 2020-02-02 是星期日，7 和 0 都表示星期日。
+		{"0 0 * * 7", time.Date(2020, 2, 2, 0, 0, 0, 0, time.UTC)},
+
+		// 日期和星期都不是 * 时，满足任何一个即可。
+		{"0 0 15 * 1", time.Date(2020, 2, 3, 0, 0, 0, 0, time.UTC)},
+
+		// 永远不会执行。
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, c := range cases {
+		s, err := parseSchedule(c.Spec)
+		a.NilError(err)
+		a.Use(&c)
+		a.Equal(s.Next(now), c.Next)
+	}
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every",
+		"@every -1s",
+		"@every abc",
+	} {
+		_, err := parseSchedule(spec)
+		a.Use(&spec)
+		a.NonNilError(err)
+	}
+}
@@ -0,0 +1,406 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	docTagName = "doc"
+
+	jsonSchemaVersion = "http://json-schema.org/draft-07/schema#"
+)
+
+// dumpConfigSchema 将所有已注册的配置结构以 JSON Schema 格式输出到 w。
+func dumpConfigSchema(w io.Writer) int {
+	schema := configSchema(append(builtinConfigBindings(), configBindings...))
+	data, err := json.MarshalIndent(schema, "", "    ")
+
+	if err != nil {
+		fmt.Fprintf(w, "go-runner: fail to dump config schema: %v\n", err)
+		return ExitCodeInvalidConfig
+	}
+
+	w.Write(data)
+	fmt.Fprintln(w)
+	return ExitCodeOK
+}
+
+// configSchema 根据 bindings 生成整个配置文件的 JSON Schema。
+//
+// 每个字段的 schema 包含字段类型、默认值和校验规则，
+// 如果字段上有 doc tag，那么 tag 的内容会作为字段的 description。
+// 多个 binding 读取同一个 section 时，它们的字段会合并到一起。
+func configSchema(bindings []*configBinding) map[string]interface{} {
+	root := map[string]interface{}{
+		"$schema":    jsonSchemaVersion,
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+	builder := newSchemaBuilder()
+
+	for _, binding := range bindings {
+		if binding.Path != "" {
+			continue
+		}
+
+		schema := builder.TypeSchema(binding.Type)
+		schema["x-go-type"] = derefType(binding.Type).String()
+		schema["x-caller"] = binding.Caller
+
+		if binding.Section == "" {
+			mergeSchema(root, schema)
+			continue
+		}
+
+		parent := root
+		parts := strings.Split(binding.Section, ".")
+
+		for _, part := range parts[:len(parts)-1] {
+			props := parent["properties"].(map[string]interface{})
+			sub, ok := props[part].(map[string]interface{})
+
+			if !ok {
+				sub = map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				}
+				props[part] = sub
+			}
+
+			if _, ok := sub["properties"]; !ok {
+				sub["properties"] = map[string]interface{}{}
+			}
+
+			parent = sub
+		}
+
+		props := parent["properties"].(map[string]interface{})
+		name := parts[len(parts)-1]
+
+		if existing, ok := props[name].(map[string]interface{}); ok {
+			mergeSchema(existing, schema)
+		} else {
+			props[name] = schema
+		}
+	}
+
+	if len(builder.definitions) > 0 {
+		root["definitions"] = builder.definitions
+	}
+
+	return root
+}
+
+// mergeSchema 将 src 的字段合并到 dst 里面。
+func mergeSchema(dst, src map[string]interface{}) {
+	srcProps, _ := src["properties"].(map[string]interface{})
+	dstProps, _ := dst["properties"].(map[string]interface{})
+
+	if dstProps == nil {
+		dstProps = map[string]interface{}{}
+		dst["properties"] = dstProps
+	}
+
+	for k, v := range srcProps {
+		if _, ok := dstProps[k]; !ok {
+			dstProps[k] = v
+		}
+	}
+
+	if required, ok := src["required"].([]string); ok {
+		existing, _ := dst["required"].([]string)
+		seen := map[string]bool{}
+
+		for _, name := range existing {
+			seen[name] = true
+		}
+
+		for _, name := range required {
+			if !seen[name] {
+				existing = append(existing, name)
+			}
+		}
+
+		dst["required"] = existing
+	}
+
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+// schemaBuilder 生成配置类型的 JSON Schema。
+//
+// 递归引用自身的 struct 无法直接展开，再次遇到这个类型时会生成一个 $ref，
+// 指向根节点 definitions 中这个类型的 schema。
+type schemaBuilder struct {
+	visiting    map[reflect.Type]bool
+	refs        map[reflect.Type]string
+	definitions map[string]interface{}
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		visiting:    map[reflect.Type]bool{},
+		refs:        map[reflect.Type]string{},
+		definitions: map[string]interface{}{},
+	}
+}
+
+// ref 返回 t 在 definitions 中的名字，不同包中名字相同的类型会加上序号区分。
+func (builder *schemaBuilder) ref(t reflect.Type) string {
+	if name, ok := builder.refs[t]; ok {
+		return name
+	}
+
+	name := t.String()
+	used := map[string]bool{}
+
+	for _, n := range builder.refs {
+		used[n] = true
+	}
+
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%v_%v", t.String(), i)
+	}
+
+	builder.refs[t] = name
+	return name
+}
+
+// TypeSchema 返回 t 对应的 JSON Schema。
+func (builder *schemaBuilder) TypeSchema(t reflect.Type) map[string]interface{} {
+	t = derefType(t)
+
+	switch t {
+	case typeOfDuration:
+		return map[string]interface{}{
+			"type":   "string",
+			"format": "duration",
+		}
+	case typeOfTime:
+		return map[string]interface{}{
+			"type":   "string",
+			"format": "date-time",
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": builder.TypeSchema(t.Elem()),
+		}
+
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": builder.TypeSchema(t.Elem()),
+		}
+
+	case reflect.Struct:
+		if builder.visiting[t] {
+			return map[string]interface{}{
+				"$ref": "#/definitions/" + builder.ref(t),
+			}
+		}
+
+		builder.visiting[t] = true
+		defer delete(builder.visiting, t)
+
+		schema := map[string]interface{}{
+			"type": "object",
+		}
+		props := map[string]interface{}{}
+		var required []string
+		builder.structSchema(props, &required, t)
+		schema["properties"] = props
+
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+
+		// 类型在展开的过程中引用了自身，需要在 definitions 中保存一份 schema。
+		if name, ok := builder.refs[t]; ok {
+			def := map[string]interface{}{}
+
+			for k, v := range schema {
+				def[k] = v
+			}
+
+			builder.definitions[name] = def
+		}
+
+		return schema
+	}
+
+	// interface{} 等类型可以是任何值。
+	return map[string]interface{}{}
+}
+
+func (builder *schemaBuilder) structSchema(props map[string]interface{}, required *[]string, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, squash, skipped := parseConfigTag(field)
+
+		if skipped {
+			continue
+		}
+
+		if squash {
+			if ft := derefType(field.Type); ft.Kind() == reflect.Struct {
+				if !builder.visiting[ft] {
+					builder.visiting[ft] = true
+					builder.structSchema(props, required, ft)
+					delete(builder.visiting, ft)
+				}
+
+				continue
+			}
+		}
+
+		schema := builder.TypeSchema(field.Type)
+
+		if doc := field.Tag.Get(docTagName); doc != "" {
+			schema["description"] = doc
+		}
+
+		if field.Tag.Get(secretTagName) == "true" {
+			schema["writeOnly"] = true
+		}
+
+		if def, ok := field.Tag.Lookup(defaultTagName); ok {
+			v := reflect.New(field.Type).Elem()
+
+			if err := setDefaultValue(v, def); err == nil {
+				schema["default"] = normalizeConfig(v)
+			}
+		}
+
+		if rules := field.Tag.Get(validateTagName); rules != "" {
+			schema["x-validate"] = rules
+
+			if applyRuleSchema(schema, field.Type, rules) {
+				*required = append(*required, name)
+			}
+		}
+
+		props[name] = schema
+	}
+}
+
+// applyRuleSchema 将 validate tag 中的规则转化成 JSON Schema 的关键字，如果字段必填则返回 true。
+func applyRuleSchema(schema map[string]interface{}, t reflect.Type, rules string) (required bool) {
+	t = derefType(t)
+
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		name, param := rule, ""
+
+		if idx := strings.Index(rule, "="); idx >= 0 {
+			name, param = rule[:idx], rule[idx+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+
+		case "min", "max":
+			if key := rangeSchemaKeyword(name, t); key != "" {
+				if n, err := strconv.ParseFloat(param, 64); err == nil {
+					schema[key] = n
+				}
+			}
+
+		case "oneof":
+			var enum []interface{}
+
+			for _, opt := range strings.Fields(param) {
+				v := reflect.New(t).Elem()
+
+				if err := setDefaultValue(v, opt); err != nil {
+					enum = nil
+					break
+				}
+
+				enum = append(enum, normalizeConfig(v))
+			}
+
+			if len(enum) > 0 {
+				schema["enum"] = enum
+			}
+
+		case "duration":
+			schema["format"] = "duration"
+
+		case "url":
+			schema["format"] = "uri"
+		}
+	}
+
+	return
+}
+
+// rangeSchemaKeyword 返回 min/max 规则在 t 类型上对应的 JSON Schema 关键字。
+// time.Duration 的范围无法用 JSON Schema 表达，返回空字符串。
+func rangeSchemaKeyword(name string, t reflect.Type) string {
+	if t == typeOfDuration {
+		return ""
+	}
+
+	var min, max string
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		min, max = "minimum", "maximum"
+	case reflect.String:
+		min, max = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array:
+		min, max = "minItems", "maxItems"
+	case reflect.Map:
+		min, max = "minProperties", "maxProperties"
+	default:
+		return ""
+	}
+
+	if name == "min" {
+		return min
+	}
+
+	return max
+}
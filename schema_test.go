@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+type testSchemaBackend struct {
+	Addr string `config:"addr" validate:"required,url"`
+}
+
+type testSchemaConfig struct {
+	Addr     string              `config:"addr" default:":8080" validate:"required" doc:"Address to listen on."`
+	Mode     string              `config:"mode" validate:"oneof=debug release"`
+	Workers  int                 `config:"workers" validate:"min=1,max=64"`
+	Timeout  time.Duration       `config:"timeout" default:"3s"`
+	Token    string              `config:"token" secret:"true"`
+	Backends []testSchemaBackend `config:"backends"`
+	Labels   map[string]string   `config:"labels"`
+}
+
+func TestConfigSchema(t *testing.T) {
+	a := assert.New(t)
+
+	defer func() {
+		configBindings = nil
+		serverHandlers = nil
+	}()
+
+	configBindings = nil
+	serverHandlers = nil
+	AddServer("http.server", func(ctx context.Context, c *testSchemaConfig) {})
+
+	buf := &bytes.Buffer{}
+	a.Equal(dumpConfigSchema(buf), ExitCodeOK)
+
+	var schema map[string]interface{}
+	a.NilError(json.Unmarshal(buf.Bytes(), &schema))
+	a.Equal(schema["$schema"], jsonSchemaVersion)
+
+	props := schema["properties"].(map[string]interface{})
+	a.Assert(props["log"] != nil)
+	a.Assert(props["runner"] != nil)
+
+	http := props["http"].(map[string]interface{})["properties"].(map[string]interface{})
+	server := http["server"].(map[string]interface{})
+	a.Equal(server["x-go-type"], "runner.testSchemaConfig")
+	a.Equal(server["required"], []interface{}{"addr"})
+
+	fields := server["properties"].(map[string]interface{})
+	a.Equal(fields["addr"], map[string]interface{}{
+		"type":        "string",
+		"default":     ":8080",
+		"description": "Address to listen on.",
+		"x-validate":  "required",
+	})
+	a.Equal(fields["mode"].(map[string]interface{})["enum"], []interface{}{"debug", "release"})
+	a.Equal(fields["workers"].(map[string]interface{})["minimum"], 1.0)
+	a.Equal(fields["workers"].(map[string]interface{})["maximum"], 64.0)
+	a.Equal(fields["timeout"], map[string]interface{}{
+		"type":    "string",
+		"format":  "duration",
+		"default": "3s",
+	})
+	a.Equal(fields["token"].(map[string]interface{})["writeOnly"], true)
+	a.Equal(fields["labels"].(map[string]interface{})["additionalProperties"], map[string]interface{}{"type": "string"})
+
+	items := fields["backends"].(map[string]interface{})["items"].(map[string]interface{})
+	a.Equal(items["required"], []interface{}{"addr"})
+	a.Equal(items["properties"].(map[string]interface{})["addr"].(map[string]interface{})["format"], "uri")
+}
+
+func TestConfigSchemaRecursive(t *testing.T) {
+	a := assert.New(t)
+
+	defer func() {
+		configBindings = nil
+		serverHandlers = nil
+	}()
+
+	configBindings = nil
+	serverHandlers = nil
+	AddServer("tree", func(ctx context.Context, c *testConfigNode) {})
+
+	buf := &bytes.Buffer{}
+	a.Equal(dumpConfigSchema(buf), ExitCodeOK)
+
+	var schema map[string]interface{}
+	a.NilError(json.Unmarshal(buf.Bytes(), &schema))
+
+	// 递归引用自身的字段使用 $ref 指向 definitions 中的 schema。
+	tree := schema["properties"].(map[string]interface{})["tree"].(map[string]interface{})
+	children := tree["properties"].(map[string]interface{})["children"].(map[string]interface{})
+	a.Equal(children["items"], map[string]interface{}{"$ref": "#/definitions/runner.testConfigNode"})
+
+	node := schema["definitions"].(map[string]interface{})["runner.testConfigNode"].(map[string]interface{})
+	a.Equal(node["properties"], tree["properties"])
+	a.Assert(node["x-caller"] == nil)
+}
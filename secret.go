@@ -0,0 +1,217 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SecretResolver 用于解析配置中的密钥引用。
+//
+// 配置中任何字符串都可以包含形如 `${scheme:ref}` 的引用，
+// runner 会在反序列化配置时找到 scheme 对应的 SecretResolver，并用 Resolve 的返回值替换整个引用。
+// 没有注册 SecretResolver 的 scheme 不会被当做引用，会原样保留；
+// 如果需要在字符串中保留 `${scheme:ref}` 本身，可以写成 `$${scheme:ref}`。
+type SecretResolver interface {
+	// Resolve 返回 ref 对应的密钥，如果密钥不存在应该返回错误。
+	Resolve(ref string) (string, error)
+}
+
+// MapSecretResolver 是一个从内存 map 中读取密钥的 SecretResolver，一般用于测试。
+type MapSecretResolver map[string]string
+
+// Resolve 返回 ref 对应的密钥。
+func (m MapSecretResolver) Resolve(ref string) (string, error) {
+	if secret, ok := m[ref]; ok {
+		return secret, nil
+	}
+
+	return "", fmt.Errorf("secret %v is not found", ref)
+}
+
+type fileSecretResolver struct{}
+
+// Resolve 读取文件 ref 的内容作为密钥，会去掉文件末尾的换行。
+func (fileSecretResolver) Resolve(ref string) (string, error) {
+	data, err := ioutil.ReadFile(ref)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+type envSecretResolver struct{}
+
+// Resolve 读取环境变量 ref 的值作为密钥。
+func (envSecretResolver) Resolve(ref string) (string, error) {
+	secret, ok := os.LookupEnv(ref)
+
+	if !ok {
+		return "", fmt.Errorf("environment variable %v is not set", ref)
+	}
+
+	return secret, nil
+}
+
+var secretResolvers = map[string]SecretResolver{
+	"file": fileSecretResolver{},
+	"env":  envSecretResolver{},
+}
+
+// RegisterSecretResolver 注册 scheme 对应的 SecretResolver，会覆盖之前注册的同名 resolver。
+// 框架内置了 file 和 env 两种 scheme，分别从文件和环境变量中读取密钥。
+//
+// 这个函数应该在 init 中调用。
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	if scheme == "" || resolver == nil {
+		return
+	}
+
+	secretResolvers[scheme] = resolver
+}
+
+// secretReference 匹配 ${scheme:ref} 形式的密钥引用，以 $$ 开头的是转义之后的普通字符串。
+var secretReference = regexp.MustCompile(`\$?\$\{([A-Za-z0-9_-]+):([^}]*)\}`)
+
+// resolveSecrets 将 v 中所有字符串里的密钥引用替换成真正的密钥，section 是 v 在配置中的路径。
+// 所有无法解析的引用都会以 validationErrors 的形式返回。
+func resolveSecrets(section string, v interface{}) error {
+	var errs validationErrors
+	resolveValue(&errs, section, reflect.ValueOf(v))
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func resolveValue(errs *validationErrors, path string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			resolveValue(errs, path, v.Elem())
+		}
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+
+		// interface 里的值无法直接修改，需要复制一份修改之后再设置回去。
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		resolveValue(errs, path, elem)
+
+		if v.CanSet() {
+			v.Set(elem)
+		}
+
+	case reflect.String:
+		if !v.CanSet() {
+			return
+		}
+
+		if s, err := resolveSecretString(v.String()); err != nil {
+			*errs = append(*errs, &validationError{
+				Path: path,
+				Err:  err,
+			})
+		} else {
+			v.SetString(s)
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+
+			if field.PkgPath != "" {
+				continue
+			}
+
+			name, squash, skipped := parseConfigTag(field)
+
+			if skipped {
+				continue
+			}
+
+			fieldPath := path
+
+			if !squash {
+				fieldPath = joinConfigPath(path, name)
+			}
+
+			resolveValue(errs, fieldPath, v.Field(i))
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			resolveValue(errs, fmt.Sprintf("%v[%v]", path, i), v.Index(i))
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		elemType := v.Type().Elem()
+
+		for _, key := range keys {
+			elem := reflect.New(elemType).Elem()
+			elem.Set(v.MapIndex(key))
+			resolveValue(errs, joinConfigPath(path, fmt.Sprint(key.Interface())), elem)
+			v.SetMapIndex(key, elem)
+		}
+	}
+}
+
+// resolveSecretString 替换 s 中所有的密钥引用。
+func resolveSecretString(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var err error
+	resolved := secretReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+
+		// $${scheme:ref} 是转义，去掉一个 $ 之后原样保留。
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+
+		matches := secretReference.FindStringSubmatch(ref)
+		scheme, name := matches[1], matches[2]
+		resolver, ok := secretResolvers[scheme]
+
+		// 没有注册的 scheme 可能只是普通的文本，例如模板或者 shell 脚本，原样保留。
+		if !ok {
+			return ref
+		}
+
+		secret, e := resolver.Resolve(name)
+
+		if e != nil {
+			err = fmt.Errorf("fail to resolve secret %v: %v", ref, e)
+			return ref
+		}
+
+		return secret
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/huandu/go-assert"
+)
+
+type testSecretConfig struct {
+	Password string            `config:"password"`
+	DSN      string            `config:"dsn"`
+	Tokens   []string          `config:"tokens"`
+	Headers  map[string]string `config:"headers"`
+	Plain    string            `config:"plain"`
+}
+
+func TestResolveSecrets(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	secretPath := filepath.Join(dir, "db_password")
+	writeTestFile(a, secretPath, "p@ssw0rd\n")
+	a.NilError(os.Setenv("GO_RUNNER_TEST_TOKEN", "env-token"))
+	defer os.Unsetenv("GO_RUNNER_TEST_TOKEN")
+
+	RegisterSecretResolver("mem", MapSecretResolver{
+		"api": "mem-secret",
+	})
+	defer delete(secretResolvers, "mem")
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf(`[log]
+log_path = %q
+
+[db]
+password = "${file:%v}"
+dsn = "mysql://root:${file:%v}@localhost:3306/db"
+tokens = ["${env:GO_RUNNER_TEST_TOKEN}", "literal"]
+plain = "no secret here"
+
+[db.headers]
+x-api-key = "${mem:api}"
+`, filepath.Join(dir, "log", "test.log"), secretPath, secretPath))
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		configHandlers = nil
+		configBindings = nil
+		clientHandlers = nil
+	}()
+
+	var loaded, received *testSecretConfig
+	configHandlers = nil
+	configBindings = nil
+	clientHandlers = nil
+	LoadConfig("db", &loaded)
+	AddClient("db", func(ctx context.Context, c *testSecretConfig) {
+		received = c
+	})
+	a.Equal(run(), ExitCodeOK)
+
+	expected := &testSecretConfig{
+		Password: "p@ssw0rd",
+		DSN:      "mysql://root:p@ssw0rd@localhost:3306/db",
+		Tokens:   []string{"env-token", "literal"},
+		Headers:  map[string]string{"x-api-key": "mem-secret"},
+		Plain:    "no secret here",
+	}
+	a.Equal(loaded, expected)
+	a.Equal(received, expected)
+
+	// 任何一个密钥不存在都会导致启动失败。
+	a.NilError(os.Unsetenv("GO_RUNNER_TEST_TOKEN"))
+	a.Equal(run(), ExitCodeInvalidConfig)
+}
+
+func TestResolveSecretString(t *testing.T) {
+	a := assert.New(t)
+
+	s, err := resolveSecretString("no reference")
+	a.NilError(err)
+	a.Equal(s, "no reference")
+
+	// 没有注册的 scheme 原样保留。
+	s, err = resolveSecretString("echo ${unknown:foo} ${HOME}")
+	a.NilError(err)
+	a.Equal(s, "echo ${unknown:foo} ${HOME}")
+
+	// $${scheme:ref} 转义成 ${scheme:ref}，不会被解析。
+	a.NilError(os.Setenv("GO_RUNNER_TEST_TOKEN", "env-token"))
+	defer os.Unsetenv("GO_RUNNER_TEST_TOKEN")
+	s, err = resolveSecretString("$${env:GO_RUNNER_TEST_TOKEN}=${env:GO_RUNNER_TEST_TOKEN}")
+	a.NilError(err)
+	a.Equal(s, "${env:GO_RUNNER_TEST_TOKEN}=env-token")
+
+	m := map[string]interface{}{
+		"foo": "${env:GO_RUNNER_TEST_NOT_SET}",
+	}
+	err = resolveSecrets("section", &m)
+	a.NonNilError(err)
+	a.Equal(err.(validationErrors)[0].Path, "section.foo")
+	a.Equal(err.Error(), "go-runner: invalid config: section.foo: fail to resolve secret ${env:GO_RUNNER_TEST_NOT_SET}: environment variable GO_RUNNER_TEST_NOT_SET is not set")
+}
@@ -2,6 +2,7 @@ package runner
 
 import (
 	"context"
+	"strings"
 	"sync"
 )
 
@@ -11,26 +12,72 @@ var serverHandlers []handler
 // 这个 handler 执行之后必须保持阻塞，直到停止服务为止才应该返回。
 func AddServer(section string, handler Handler) {
 	const skip = 1
-	h, err := parseHandler(section, handler)
+	addServer(skip+1, []string{section}, handler)
+}
 
-	if err != nil {
-		h = makeErrorHandler(skip, err)
-	}
+// AddServerSections 注册一个自启动的服务，handler 在 ctx 之后的参数依次从 sections 中读取配置。
+//
+// 例如，以下 handler 的 server 参数读取 [http.server]，tracing 参数读取 [tracing]：
+//
+//     runner.AddServerSections([]string{"http.server", "tracing"}, func(ctx context.Context, server *ServerConfig, tracing *TracingConfig) error {
+//         // ……
+//     })
+func AddServerSections(sections []string, handler Handler) {
+	const skip = 1
+	addServer(skip+1, sections, handler)
+}
 
-	serverHandlers = append(serverHandlers, h)
+func addServer(skip int, sections []string, handler Handler) {
+	info := &HandlerInfo{
+		Phase:   PhaseServer,
+		Section: strings.Join(sections, ","),
+		Caller:  findCaller(skip),
+	}
+	serverHandlers = append(serverHandlers, intercept(info, registerHandler(skip, sections, handler)))
 }
 
+// runServers 运行所有 server 和定时任务。
+// 所有 server 都返回之后，定时任务会停止调度；如果没有任何 server，定时任务会一直运行到 ctx 被取消。
 func runServers(ctx context.Context) int {
-	if len(serverHandlers) == 0 {
+	jobCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+
+	jobHandlers := make([]handler, 0, len(jobs))
+
+	for _, j := range jobs {
+		jobHandlers = append(jobHandlers, j.Serve)
+	}
+
+	jobCode := make(chan int, 1)
+	go func() {
+		jobCode <- serveAll(jobCtx, jobHandlers)
+	}()
+
+	code := serveAll(ctx, serverHandlers)
+
+	if len(serverHandlers) > 0 {
+		stopJobs()
+	}
+
+	if c := <-jobCode; code == ExitCodeOK {
+		code = c
+	}
+
+	return code
+}
+
+// serveAll 并发执行所有 handlers，等待它们全部返回，返回第一个失败的错误码。
+func serveAll(ctx context.Context, handlers []handler) int {
+	if len(handlers) == 0 {
 		return ExitCodeOK
 	}
 
-	sz := len(serverHandlers)
+	sz := len(handlers)
 	codes := make([]int, sz)
 	wg := sync.WaitGroup{}
 	wg.Add(sz)
 
-	for i, h := range serverHandlers {
+	for i, h := range handlers {
 		go func(ctx context.Context, idx int, h handler) {
 			defer wg.Done()
 			codes[idx] = h.Call(ctx)
@@ -0,0 +1,361 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altstory/go-log"
+)
+
+const defaultConfigSourceInterval = 30 * time.Second
+
+// ConfigLayer 是 ConfigSource 中的一份配置。
+type ConfigLayer struct {
+	Name string // Name 是配置的名字，例如文件路径或者 URL，会输出到日志和 -print-config 中。
+	Data []byte // Data 是 TOML 格式的配置内容，规则与额外配置文件完全相同，同样支持 _deletes。
+}
+
+// ConfigSource 是一个配置来源，runner 会在加载完所有配置文件之后追加 ConfigSource 中的配置。
+type ConfigSource interface {
+	// Load 返回配置来源中当前的所有配置，越靠后的配置优先级越高。
+	Load(ctx context.Context) ([]*ConfigLayer, error)
+
+	// Watch 监听配置变化，每次配置发生变化时都会用最新的配置调用 onChange。
+	// Watch 会一直阻塞直到 ctx 结束。
+	Watch(ctx context.Context, onChange func(layers []*ConfigLayer)) error
+}
+
+// ConfigSourceFactory 根据 URL 创建一个 ConfigSource。
+type ConfigSourceFactory func(u *url.URL) (ConfigSource, error)
+
+var configSourceFactories = map[string]ConfigSourceFactory{
+	"file": func(u *url.URL) (ConfigSource, error) {
+		path := u.Path
+
+		if u.Opaque != "" {
+			path = u.Opaque
+		}
+
+		return NewFileConfigSource(path, *flagConfigSourceInterval), nil
+	},
+	"http": func(u *url.URL) (ConfigSource, error) {
+		return NewHTTPConfigSource(u.String(), *flagConfigSourceInterval), nil
+	},
+	"https": func(u *url.URL) (ConfigSource, error) {
+		return NewHTTPConfigSource(u.String(), *flagConfigSourceInterval), nil
+	},
+}
+
+// RegisterConfigSource 注册 scheme 对应的 ConfigSourceFactory，通过 -config-source 指定的 URL 会根据 scheme 创建 ConfigSource。
+// 框架内置了 file、http 和 https 三种 scheme。
+//
+// 这个函数应该在 init 中调用。
+func RegisterConfigSource(scheme string, factory ConfigSourceFactory) {
+	if scheme == "" || factory == nil {
+		return
+	}
+
+	configSourceFactories[scheme] = factory
+}
+
+func newConfigSource(rawurl string) (ConfigSource, error) {
+	u, err := url.Parse(rawurl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	factory, ok := configSourceFactories[u.Scheme]
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported config source scheme %v", u.Scheme)
+	}
+
+	return factory(u)
+}
+
+type fileConfigSource struct {
+	path   string
+	poller configPoller
+}
+
+// NewFileConfigSource 创建一个读取本地文件的 ConfigSource。
+// 如果 path 是目录，会按文件名字典序读取目录下所有 .conf 文件。
+// Watch 会每隔 interval 检查一次文件内容是否发生变化。
+func NewFileConfigSource(path string, interval time.Duration) ConfigSource {
+	if interval <= 0 {
+		interval = defaultConfigSourceInterval
+	}
+
+	return &fileConfigSource{
+		path: path,
+		poller: configPoller{
+			interval: interval,
+		},
+	}
+}
+
+func (source *fileConfigSource) Load(ctx context.Context) ([]*ConfigLayer, error) {
+	layers, err := source.load(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	source.poller.Loaded(layers)
+	return layers, nil
+}
+
+func (source *fileConfigSource) load(ctx context.Context) ([]*ConfigLayer, error) {
+	info, err := os.Stat(source.path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{source.path}
+
+	if info.IsDir() {
+		infos, err := ioutil.ReadDir(source.path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		paths = nil
+
+		for _, info := range infos {
+			name := info.Name()
+
+			if info.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != confFileExt {
+				continue
+			}
+
+			paths = append(paths, filepath.Join(source.path, name))
+		}
+	}
+
+	layers := make([]*ConfigLayer, 0, len(paths))
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		layers = append(layers, &ConfigLayer{
+			Name: path,
+			Data: data,
+		})
+	}
+
+	return layers, nil
+}
+
+func (source *fileConfigSource) Watch(ctx context.Context, onChange func(layers []*ConfigLayer)) error {
+	return source.poller.Poll(ctx, source.load, onChange)
+}
+
+type httpConfigSource struct {
+	url    string
+	client *http.Client
+	poller configPoller
+}
+
+// NewHTTPConfigSource 创建一个通过 HTTP GET 读取配置的 ConfigSource，响应内容必须是 TOML 格式的配置。
+// Watch 会每隔 interval 请求一次 url 检查配置是否发生变化。
+func NewHTTPConfigSource(url string, interval time.Duration) ConfigSource {
+	if interval <= 0 {
+		interval = defaultConfigSourceInterval
+	}
+
+	return &httpConfigSource{
+		url: url,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		poller: configPoller{
+			interval: interval,
+		},
+	}
+}
+
+func (source *httpConfigSource) Load(ctx context.Context) ([]*ConfigLayer, error) {
+	layers, err := source.load(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	source.poller.Loaded(layers)
+	return layers, nil
+}
+
+func (source *httpConfigSource) load(ctx context.Context) ([]*ConfigLayer, error) {
+	req, err := http.NewRequest(http.MethodGet, source.url, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := source.client.Do(req.WithContext(ctx))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v from %v", resp.StatusCode, source.url)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []*ConfigLayer{
+		{
+			Name: source.url,
+			Data: data,
+		},
+	}, nil
+}
+
+func (source *httpConfigSource) Watch(ctx context.Context, onChange func(layers []*ConfigLayer)) error {
+	return source.poller.Poll(ctx, source.load, onChange)
+}
+
+// configPoller 定期读取配置，并在配置内容与最近一次读取的内容不同时发出通知。
+type configPoller struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	last []*ConfigLayer
+}
+
+// Loaded 记录最近一次读取到的配置。
+func (poller *configPoller) Loaded(layers []*ConfigLayer) {
+	poller.mu.Lock()
+	defer poller.mu.Unlock()
+	poller.last = layers
+}
+
+// Changed 判断 layers 是否与最近一次读取到的配置不同，如果不同则记录 layers。
+func (poller *configPoller) Changed(layers []*ConfigLayer) bool {
+	poller.mu.Lock()
+	defer poller.mu.Unlock()
+
+	if equalConfigLayers(poller.last, layers) {
+		return false
+	}
+
+	poller.last = layers
+	return true
+}
+
+// Poll 每隔 interval 调用一次 load，一旦配置内容发生变化就调用 onChange，直到 ctx 结束。
+// 读取配置失败只会输出日志，不会停止轮询。
+func (poller *configPoller) Poll(ctx context.Context,
+	load func(ctx context.Context) ([]*ConfigLayer, error), onChange func(layers []*ConfigLayer)) error {
+	ticker := time.NewTicker(poller.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		layers, err := load(ctx)
+
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warnf(ctx, "err=%v||go-runner: fail to load config source", err)
+			}
+
+			continue
+		}
+
+		if poller.Changed(layers) {
+			onChange(layers)
+		}
+	}
+}
+
+func equalConfigLayers(a, b []*ConfigLayer) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Name != b[i].Name || !bytes.Equal(a[i].Data, b[i].Data) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// watchConfigSource 监听 ConfigSource 的变化，直到 ctx 结束。
+func watchConfigSource(ctx context.Context) {
+	runner := runnerFromContext(ctx)
+
+	if runner.Source == nil {
+		return
+	}
+
+	err := runner.Source.Watch(ctx, func(layers []*ConfigLayer) {
+		reloadConfig(ctx, layers)
+	})
+
+	if err != nil && ctx.Err() == nil {
+		log.Errorf(ctx, "err=%v||go-runner: fail to watch config source", err)
+	}
+}
+
+var errInvalidReloadedConfig = errors.New("go-runner: reloaded config is invalid")
+
+// configReloadMu 保证同一时间只有一次配置重新加载。
+var configReloadMu sync.Mutex
+
+// reloadConfig 使用 layers 重新加载所有配置，只有新配置通过所有检查之后才会替换当前配置。
+// 通过 LoadConfig 注册的配置只会在启动时读取一次，不会被重新加载。
+func reloadConfig(ctx context.Context, layers []*ConfigLayer) error {
+	configReloadMu.Lock()
+	defer configReloadMu.Unlock()
+
+	runner := runnerFromContext(ctx)
+	c, files, err := mergeConfigFiles(runner.ConfigPath, layers)
+	code := ExitCodeInvalidConfig
+
+	if err != nil {
+		log.Errorf(ctx, "err=%v||go-runner: fail to load reloaded config", err)
+	} else {
+		code = checkConfigs(ctx, c)
+	}
+
+	if code != ExitCodeOK {
+		log.Errorf(ctx, "config_files=%v||go-runner: fail to reload config and keep using current config", files)
+		return errInvalidReloadedConfig
+	}
+
+	recordConfigChanges(ctx, runner.CurrentConfig(), c, files)
+	runner.SetConfig(c)
+	log.Infof(ctx, "config_files=%v||go-runner: config is reloaded", files)
+	return nil
+}
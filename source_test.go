@@ -0,0 +1,233 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestFileConfigSource(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	writeTestFile(a, filepath.Join(dir, "b.conf"), "[foo]\nbar = 2\n")
+	writeTestFile(a, filepath.Join(dir, "a.conf"), "[foo]\nbar = 1\n")
+	writeTestFile(a, filepath.Join(dir, "ignored.txt"), "[foo]\nbar = 3\n")
+
+	source, err := newConfigSource("file://" + dir)
+	a.NilError(err)
+	layers, err := source.Load(context.Background())
+	a.NilError(err)
+	a.Equal(layers, []*ConfigLayer{
+		{Name: filepath.Join(dir, "a.conf"), Data: []byte("[foo]\nbar = 1\n")},
+		{Name: filepath.Join(dir, "b.conf"), Data: []byte("[foo]\nbar = 2\n")},
+	})
+
+	source = NewFileConfigSource(filepath.Join(dir, "a.conf"), 0)
+	layers, err = source.Load(context.Background())
+	a.NilError(err)
+	a.Equal(len(layers), 1)
+
+	_, err = newConfigSource("unknown://foo")
+	a.NonNilError(err)
+}
+
+type testConfigServer struct {
+	mu   sync.Mutex
+	conf string
+}
+
+func (s *testConfigServer) Set(conf string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conf = conf
+}
+
+func (s *testConfigServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conf == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Write([]byte(s.conf))
+}
+
+func TestHTTPConfigSource(t *testing.T) {
+	a := assert.New(t)
+	cs := &testConfigServer{}
+	cs.Set("[foo]\nbar = 1\n")
+	server := httptest.NewServer(cs)
+	defer server.Close()
+
+	source := NewHTTPConfigSource(server.URL, 10*time.Millisecond)
+	layers, err := source.Load(context.Background())
+	a.NilError(err)
+	a.Equal(layers, []*ConfigLayer{
+		{Name: server.URL, Data: []byte("[foo]\nbar = 1\n")},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan []*ConfigLayer, 1)
+	done := make(chan error)
+	go func() {
+		done <- source.Watch(ctx, func(layers []*ConfigLayer) {
+			changed <- layers
+			cancel()
+		})
+	}()
+
+	cs.Set("[foo]\nbar = 2\n")
+
+	select {
+	case layers := <-changed:
+		a.Equal(string(layers[0].Data), "[foo]\nbar = 2\n")
+	case <-time.After(5 * time.Second):
+		t.Fatalf("config change is not detected")
+	}
+
+	a.NilError(<-done)
+
+	cs.Set("")
+	_, err = source.Load(context.Background())
+	a.NonNilError(err)
+}
+
+func TestRunWithConfigSource(t *testing.T) {
+	a := assert.New(t)
+	cs := &testConfigServer{}
+	cs.Set("[foo]\nbar = 2\n")
+	server := httptest.NewServer(cs)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[foo]\nbar = 1\nbaz = 1\n", filepath.Join(dir, "log", "test.log")))
+
+	old := *flagConfig
+	*flagConfig = confPath
+	*flagConfigSource = server.URL
+	defer func() {
+		*flagConfig = old
+		*flagConfigSource = ""
+		configHandlers = nil
+		configBindings = nil
+		clientHandlers = nil
+	}()
+
+	type sourceConfig struct {
+		Bar int `config:"bar" validate:"max=10"`
+		Baz int `config:"baz"`
+	}
+
+	var loaded *sourceConfig
+	configHandlers = nil
+	configBindings = nil
+	clientHandlers = nil
+	LoadConfig("foo", &loaded)
+	AddClient("foo", func(ctx context.Context, c *sourceConfig) {
+		a.Equal(c, &sourceConfig{Bar: 2, Baz: 1})
+	})
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(loaded, &sourceConfig{Bar: 2, Baz: 1})
+
+	// 重新加载配置之后，当前配置会被替换。
+	runner := &runnerContext{}
+	ctx := context.WithValue(context.Background(), keyRunnerContext, runner)
+	c, _, err := loadConfigFiles(ctx, confPath)
+	a.NilError(err)
+	runner.Config = c
+	runner.ConfigPath = confPath
+
+	var current *sourceConfig
+	a.NilError(reloadConfig(ctx, []*ConfigLayer{{Name: "reload", Data: []byte("[foo]\nbar = 3\n")}}))
+	a.NilError(unmarshalConfig(runner.CurrentConfig(), "foo", &current))
+	a.Equal(current, &sourceConfig{Bar: 3, Baz: 1})
+
+	// LoadConfig 注册的配置只在启动时读取。
+	a.Equal(loaded, &sourceConfig{Bar: 2, Baz: 1})
+
+	// 不合法的配置不会替换当前配置。
+	reloadedConfig := runner.CurrentConfig()
+	a.NonNilError(reloadConfig(ctx, []*ConfigLayer{{Name: "invalid", Data: []byte("[foo]\nbar = 100\n")}}))
+	a.Assert(runner.CurrentConfig() == reloadedConfig)
+
+	cs.Set("")
+	a.Equal(run(), ExitCodeInvalidConfig)
+}
+
+type slowWatchConfigSource struct {
+	returned chan struct{}
+}
+
+func (s *slowWatchConfigSource) Load(ctx context.Context) ([]*ConfigLayer, error) {
+	return nil, nil
+}
+
+func (s *slowWatchConfigSource) Watch(ctx context.Context, onChange func(layers []*ConfigLayer)) error {
+	<-ctx.Done()
+	time.Sleep(50 * time.Millisecond)
+	close(s.returned)
+	return nil
+}
+
+func TestWatchConfigSourceBeforeExit(t *testing.T) {
+	a := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n", filepath.Join(dir, "log", "test.log")))
+
+	source := &slowWatchConfigSource{
+		returned: make(chan struct{}),
+	}
+	RegisterConfigSource("slow-watch", func(u *url.URL) (ConfigSource, error) {
+		return source, nil
+	})
+
+	old := *flagConfig
+	*flagConfig = confPath
+	*flagConfigSource = "slow-watch://test"
+	defer func() {
+		*flagConfig = old
+		*flagConfigSource = ""
+		delete(configSourceFactories, "slow-watch")
+		onExitHandlers = nil
+		resetTestInjection()
+	}()
+
+	// 退出函数执行时，配置来源的监听一定已经结束。
+	watching := true
+	resetTestInjection()
+	onExitHandlers = nil
+	OnExit(func(ctx context.Context) {
+		select {
+		case <-source.returned:
+			watching = false
+		default:
+		}
+	})
+
+	a.Equal(run(), ExitCodeOK)
+	a.Assert(!watching)
+}
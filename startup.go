@@ -0,0 +1,122 @@
+package runner
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/altstory/go-log"
+)
+
+// PhaseConfig 表示通过 LoadConfig 注册的配置 handler。
+const PhaseConfig Phase = "config"
+
+// StartupRecord 是启动过程中一个 handler 的耗时。
+type StartupRecord struct {
+	Phase    Phase         `json:"phase"`    // Phase 是 handler 所在的生命周期阶段。
+	Section  string        `json:"section"`  // Section 是 handler 读取的配置 section，可能为空。
+	Caller   string        `json:"caller"`   // Caller 是注册 handler 的调用方。
+	Duration time.Duration `json:"duration"` // Duration 是 handler 的执行耗时。
+}
+
+// StartupReport 是服务的启动耗时报告。
+type StartupReport struct {
+	StartTime time.Time        `json:"start_time"` // StartTime 是服务开始启动的时间。
+	Ready     time.Duration    `json:"ready"`      // Ready 是从开始启动到所有 server 开始运行的总耗时。
+	Records   []*StartupRecord `json:"records"`    // Records 是启动过程中所有 handler 的耗时，按耗时从长到短排序。
+}
+
+var startupReport struct {
+	mu     sync.Mutex
+	report *StartupReport
+}
+
+// Startup 返回最近一次启动的耗时报告，如果服务还没有启动完成则返回 nil。
+func Startup() *StartupReport {
+	startupReport.mu.Lock()
+	defer startupReport.mu.Unlock()
+	return startupReport.report
+}
+
+// Stats 将启动耗时转化成以毫秒为单位的统计值。
+//
+// 总耗时的 key 是 startup.ready，每个 handler 的 key 是 startup.<phase>.<section>，
+// 相同 key 的耗时会累加到一起。
+func (report *StartupReport) Stats() *Stats {
+	stats := &Stats{}
+
+	if report == nil {
+		return stats
+	}
+
+	stats.Set("startup.ready", int(report.Ready/time.Millisecond))
+
+	for _, record := range report.Records {
+		key := "startup." + string(record.Phase)
+
+		if record.Section != "" {
+			key += "." + record.Section
+		}
+
+		stats.Add(key, int(record.Duration/time.Millisecond))
+	}
+
+	return stats
+}
+
+// measure 返回一个会记录 h 执行耗时的 handler，只有服务启动过程中的耗时才会被记录。
+func measure(info *HandlerInfo, h handler) handler {
+	return func(ctx context.Context) int {
+		start := time.Now()
+		code := h.CallWithInfo(ctx, info)
+		runnerFromContext(ctx).AddStartupRecord(&StartupRecord{
+			Phase:    info.Phase,
+			Section:  info.Section,
+			Caller:   info.Caller,
+			Duration: time.Since(start),
+		})
+		return code
+	}
+}
+
+// AddStartupRecord 记录一个 handler 的启动耗时，服务启动完成之后调用不会有任何效果。
+func (runner *runnerContext) AddStartupRecord(record *StartupRecord) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	if runner.Startup == nil {
+		return
+	}
+
+	runner.Startup.Records = append(runner.Startup.Records, record)
+}
+
+// finishStartup 结束启动耗时统计，输出启动报告并保存起来供 Startup 读取。
+func finishStartup(ctx context.Context) {
+	runner := runnerFromContext(ctx)
+	runner.mu.Lock()
+	report := runner.Startup
+	runner.Startup = nil
+	runner.mu.Unlock()
+
+	if report == nil {
+		return
+	}
+
+	report.Ready = time.Since(report.StartTime)
+	sort.SliceStable(report.Records, func(i, j int) bool {
+		return report.Records[i].Duration > report.Records[j].Duration
+	})
+
+	for _, record := range report.Records {
+		log.Infof(ctx, "phase=%v||section=%v||caller=%v||duration=%v||go-runner: startup report",
+			record.Phase, record.Section, record.Caller, record.Duration)
+	}
+
+	log.Infof(ctx, "duration=%v||go-runner: server is ready", report.Ready)
+
+	startupReport.mu.Lock()
+	defer startupReport.mu.Unlock()
+	startupReport.report = report
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestStartupReport(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer resetTestInjection()
+	resetTestInjection()
+
+	var foo *fooConfig
+	LoadConfig("foo", &foo)
+	AddClient("foo", func(ctx context.Context, c *fooConfig) {
+		time.Sleep(20 * time.Millisecond)
+	})
+	OnStart(func(ctx context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	AddServer("", func(ctx context.Context) {
+		// 服务启动完成之后才能拿到启动报告。
+		a.Assert(Startup() != nil)
+	})
+	a.Equal(run(), ExitCodeOK)
+
+	report := Startup()
+	a.Equal(len(report.Records), 3)
+
+	client, start, config := report.Records[0], report.Records[1], report.Records[2]
+	a.Equal(client.Phase, PhaseClient)
+	a.Equal(client.Section, "foo")
+	a.Assert(strings.HasPrefix(client.Caller, "startup_test.go:"))
+	a.Assert(client.Duration >= 20*time.Millisecond)
+	a.Equal(start.Phase, PhaseStart)
+	a.Assert(start.Duration >= 10*time.Millisecond)
+	a.Equal(config.Phase, PhaseConfig)
+	a.Equal(config.Section, "foo")
+	a.Assert(strings.HasPrefix(config.Caller, "startup_test.go:"))
+	a.Assert(report.Ready >= client.Duration+start.Duration)
+
+	stats := map[string]interface{}{}
+
+	for _, info := range report.Stats().Info() {
+		stats[info.Key] = info.Value
+	}
+
+	a.Equal(len(stats), 4)
+	a.Assert(stats["startup.client.foo"].(int) >= 20)
+	a.Assert(stats["startup.ready"].(int) >= 30)
+}
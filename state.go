@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	"github.com/altstory/go-log"
+)
+
+// LifecycleState 是 runner 的生命周期状态。
+type LifecycleState int
+
+// 所有生命周期状态，服务启动时会依次经过这些状态。
+const (
+	StateInitializing       LifecycleState = iota // StateInitializing 表示正在加载配置和初始化日志。
+	StateConfiguringClients                       // StateConfiguringClients 表示正在读取业务配置和初始化 client。
+	StateStarting                                 // StateStarting 表示正在执行 OnStart 注册的函数。
+	StateRunning                                  // StateRunning 表示所有 server 都已经开始运行。
+	StateDraining                                 // StateDraining 表示服务收到了停止信号，正在等待 server 退出。
+	StateStopping                                 // StateStopping 表示正在执行 OnExit 注册的函数。
+	StateStopped                                  // StateStopped 表示服务已经正常退出。
+	StateFailed                                   // StateFailed 表示服务因为出错而退出。
+)
+
+var stateNames = map[LifecycleState]string{
+	StateInitializing:       "Initializing",
+	StateConfiguringClients: "ConfiguringClients",
+	StateStarting:           "Starting",
+	StateRunning:            "Running",
+	StateDraining:           "Draining",
+	StateStopping:           "Stopping",
+	StateStopped:            "Stopped",
+	StateFailed:             "Failed",
+}
+
+func (state LifecycleState) String() string {
+	if name, ok := stateNames[state]; ok {
+		return name
+	}
+
+	return "Unknown"
+}
+
+// StateChangeListener 在生命周期状态从 from 变成 to 时被调用。
+type StateChangeListener func(ctx context.Context, from, to LifecycleState)
+
+var lifecycle struct {
+	mu        sync.Mutex
+	state     LifecycleState
+	listeners []StateChangeListener
+
+	// notifyMu 保证状态变化和通知监听函数是一个整体，监听函数总是按照状态变化的顺序收到通知。
+	notifyMu sync.Mutex
+}
+
+// State 返回 runner 当前的生命周期状态。
+func State() LifecycleState {
+	lifecycle.mu.Lock()
+	defer lifecycle.mu.Unlock()
+	return lifecycle.state
+}
+
+// Ready 返回服务是否可以接收流量，只有处于 StateRunning 状态时才返回 true，适合用来实现就绪检查接口。
+func Ready() bool {
+	return State() == StateRunning
+}
+
+// OnStateChange 注册一个生命周期状态变化的监听函数。
+//
+// 每次状态变化时，所有监听函数都会按照注册顺序在发生变化的 goroutine 里同步执行，
+// 执行完所有监听函数之后 runner 才会进入下一个阶段。
+// 监听函数应该尽快返回，并且不能在监听函数中调用 OnStateChange。
+func OnStateChange(listener StateChangeListener) {
+	if listener == nil {
+		return
+	}
+
+	lifecycle.mu.Lock()
+	defer lifecycle.mu.Unlock()
+	lifecycle.listeners = append(lifecycle.listeners, listener)
+}
+
+// setState 将生命周期状态设置为 to。
+func setState(ctx context.Context, to LifecycleState) {
+	changeState(ctx, nil, to)
+}
+
+// changeState 在当前状态满足 cond 时将状态设置为 to，cond 为 nil 表示不检查当前状态。
+// 状态发生变化时会依次调用所有监听函数，并发的状态变化会依次执行，
+// 前一次变化的所有监听函数返回之后才会开始下一次变化。
+func changeState(ctx context.Context, cond func(from LifecycleState) bool, to LifecycleState) {
+	lifecycle.notifyMu.Lock()
+	defer lifecycle.notifyMu.Unlock()
+
+	lifecycle.mu.Lock()
+	from := lifecycle.state
+
+	if from == to || (cond != nil && !cond(from)) {
+		lifecycle.mu.Unlock()
+		return
+	}
+
+	lifecycle.state = to
+	listeners := lifecycle.listeners
+	lifecycle.mu.Unlock()
+
+	log.Infof(ctx, "from=%v||to=%v||go-runner: state is changed", from, to)
+
+	for _, listener := range listeners {
+		callStateChangeListener(ctx, listener, from, to)
+	}
+}
+
+func callStateChangeListener(ctx context.Context, listener StateChangeListener, from, to LifecycleState) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf(ctx, "from=%v||to=%v||go-runner: caught a panic in state change listener: %v\n%v", from, to, r, string(debug.Stack()))
+		}
+	}()
+
+	listener(ctx, from, to)
+}
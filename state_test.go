@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestLifecycleState(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer func() {
+		resetTestInjection()
+		onExitHandlers = nil
+		lifecycle.listeners = nil
+	}()
+	resetTestInjection()
+	onExitHandlers = nil
+	lifecycle.listeners = nil
+
+	var transitions []string
+	OnStateChange(func(ctx context.Context, from, to LifecycleState) {
+		transitions = append(transitions, from.String()+"->"+to.String())
+	})
+
+	// 监听函数 panic 不会影响服务运行。
+	OnStateChange(func(ctx context.Context, from, to LifecycleState) {
+		panic("should be recovered")
+	})
+
+	var states []LifecycleState
+	AddClient("", func(ctx context.Context) {
+		states = append(states, State())
+	})
+	OnStart(func(ctx context.Context) error {
+		states = append(states, State())
+		return nil
+	})
+	AddServer("", func(ctx context.Context) {
+		states = append(states, State())
+	})
+	OnExit(func(ctx context.Context) {
+		states = append(states, State())
+	})
+
+	lifecycle.state = StateStopped
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(states, []LifecycleState{StateConfiguringClients, StateStarting, StateRunning, StateStopping})
+	a.Equal(State(), StateStopped)
+	a.Equal(transitions, []string{
+		"Stopped->Initializing",
+		"Initializing->ConfiguringClients",
+		"ConfiguringClients->Starting",
+		"Starting->Running",
+		"Running->Stopping",
+		"Stopping->Stopped",
+	})
+
+	// 某个 server 退出并不意味着服务开始退出，其他 server 看到的状态仍然是 StateRunning。
+	resetTestInjection()
+	returned := make(chan struct{})
+	var state LifecycleState
+	AddServer("", func(ctx context.Context) {
+		close(returned)
+	})
+	AddServer("", func(ctx context.Context) {
+		<-returned
+		time.Sleep(10 * time.Millisecond)
+		state = State()
+	})
+	transitions = nil
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(state, StateRunning)
+	a.Equal(transitions[len(transitions)-3:], []string{
+		"Starting->Running",
+		"Running->Stopping",
+		"Stopping->Stopped",
+	})
+
+	transitions = nil
+	OnStart(func(ctx context.Context) error {
+		return context.Canceled
+	})
+	a.Equal(run(), ExitCodeHandlerError)
+	a.Equal(State(), StateFailed)
+	a.Equal(transitions, []string{
+		"Stopped->Initializing",
+		"Initializing->ConfiguringClients",
+		"ConfiguringClients->Starting",
+		"Starting->Stopping",
+		"Stopping->Failed",
+	})
+	a.Equal(LifecycleState(100).String(), "Unknown")
+}
+
+func TestStateChangeOrder(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	defer func() {
+		lifecycle.listeners = nil
+		lifecycle.state = StateStopped
+	}()
+	lifecycle.listeners = nil
+	lifecycle.state = StateRunning
+
+	var transitions [][2]LifecycleState
+	OnStateChange(func(ctx context.Context, from, to LifecycleState) {
+		// 让前一次通知尽量慢一些，后一次状态变化不能先通知到监听函数。
+		time.Sleep(time.Millisecond)
+		transitions = append(transitions, [2]LifecycleState{from, to})
+	})
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		to := StateDraining
+
+		if i%2 == 1 {
+			to = StateStopping
+		}
+
+		wg.Add(1)
+		go func(to LifecycleState) {
+			defer wg.Done()
+			setState(ctx, to)
+		}(to)
+	}
+
+	wg.Wait()
+
+	last := StateRunning
+
+	for _, transition := range transitions {
+		a.Equal(transition[0], last)
+		last = transition[1]
+	}
+
+	a.Equal(last, State())
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/altstory/go-config"
+	"github.com/altstory/go-log"
+)
+
+// builtinConfigBindings 返回 runner 自己读取的配置。
+func builtinConfigBindings() []*configBinding {
+	return []*configBinding{
+		{
+			Section: logSection,
+			Type:    reflect.TypeOf(log.Config{}),
+			Caller:  "go-runner",
+		},
+		{
+			Section: runnerSection,
+			Type:    reflect.TypeOf(runnerConfig{}),
+			Caller:  "go-runner",
+		},
+	}
+}
+
+// unknownConfigKeys 返回 c 中所有没有被 bindings 读取的字段，结果按字典序排列且不重复。
+//
+// 只有使用默认配置文件的 binding 才会被考虑，通过 LoadConfigFile 指定的配置文件不做检查。
+func unknownConfigKeys(c *config.Config, bindings []*configBinding) ([]string, error) {
+	var raw interface{}
+
+	if err := c.Unmarshal("", &raw); err != nil {
+		return nil, err
+	}
+
+	consumed := map[string]bool{}
+
+	for _, binding := range append(builtinConfigBindings(), bindings...) {
+		if binding.Path != "" {
+			continue
+		}
+
+		consumeConfigKeys(consumed, binding.Section, binding.Type)
+	}
+
+	var keys []string
+	collectConfigKeys(&keys, "", reflect.ValueOf(raw))
+	sort.Strings(keys)
+
+	var unknown []string
+
+	for i, key := range keys {
+		if i > 0 && keys[i-1] == key {
+			continue
+		}
+
+		if !isConfigKeyConsumed(consumed, key) {
+			unknown = append(unknown, key)
+		}
+	}
+
+	return unknown, nil
+}
+
+// consumeConfigKeys 将 t 类型在 path 下会读取的字段记录到 consumed 里面。
+//
+// struct 会逐个字段展开，其他类型的字段会读取 path 下的所有内容。
+// 递归引用自身的 struct 无法逐个字段展开，再次遇到这个类型时会认为 path 下的所有内容都会被读取。
+func consumeConfigKeys(consumed map[string]bool, path string, t reflect.Type) {
+	consumeTypeKeys(consumed, map[reflect.Type]bool{}, path, t)
+}
+
+// consumeTypeKeys 是 consumeConfigKeys 的实现，visiting 记录了正在展开的 struct 类型。
+func consumeTypeKeys(consumed map[string]bool, visiting map[reflect.Type]bool, path string, t reflect.Type) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct || t == typeOfTime || visiting[t] {
+		consumed[path] = true
+		return
+	}
+
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, squash, skipped := parseConfigTag(field)
+
+		if skipped {
+			continue
+		}
+
+		if squash {
+			consumeTypeKeys(consumed, visiting, path, field.Type)
+			continue
+		}
+
+		consumeTypeKeys(consumed, visiting, joinConfigPath(path, name), field.Type)
+	}
+}
+
+// collectConfigKeys 收集 v 中所有叶子节点的路径，数组中的表会使用数组自身的路径。
+func collectConfigKeys(keys *[]string, path string, v reflect.Value) {
+	for v.IsValid() && v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			collectConfigKeys(keys, joinConfigPath(path, key.String()), v.MapIndex(key))
+		}
+
+		return
+
+	case reflect.Slice, reflect.Array:
+		isTable := false
+
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i)
+
+			for elem.Kind() == reflect.Interface {
+				elem = elem.Elem()
+			}
+
+			if elem.Kind() == reflect.Map {
+				isTable = true
+				collectConfigKeys(keys, path, elem)
+			}
+		}
+
+		if isTable {
+			return
+		}
+	}
+
+	*keys = append(*keys, path)
+}
+
+// isConfigKeyConsumed 判断 key 本身或者 key 的任何一个上级路径是否已经被读取。
+func isConfigKeyConsumed(consumed map[string]bool, key string) bool {
+	if consumed[""] {
+		return true
+	}
+
+	for {
+		if consumed[key] {
+			return true
+		}
+
+		idx := strings.LastIndex(key, ".")
+
+		if idx < 0 {
+			return false
+		}
+
+		key = key[:idx]
+	}
+}
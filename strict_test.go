@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/altstory/go-config"
+	"github.com/huandu/go-assert"
+)
+
+type testStrictBackend struct {
+	Addr string `config:"addr"`
+}
+
+type testStrictConfig struct {
+	Addr     string              `config:"addr"`
+	Backends []testStrictBackend `config:"backends"`
+	Extra    map[string]string   `config:"extra"`
+}
+
+func TestUnknownConfigKeys(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "service.conf")
+	writeTestFile(a, confPath, `[log]
+log_levle = "debug"
+
+[runner]
+strict_config = true
+
+[server]
+addr = ":8080"
+adr = ":8081"
+
+[server.extra]
+anything = "ok"
+
+[[server.backends]]
+addr = "10.0.0.1:80"
+weight = 1
+
+[[server.backends]]
+addr = "10.0.0.2:80"
+weight = 2
+
+[unused]
+foo = 1
+`)
+
+	c, err := config.LoadFile(confPath)
+	a.NilError(err)
+
+	unknown, err := unknownConfigKeys(c, []*configBinding{
+		{
+			Section: "server",
+			Type:    reflect.TypeOf(&testStrictConfig{}),
+		},
+		{
+			Path:    "other.conf",
+			Section: "unused",
+			Type:    reflect.TypeOf(&testStrictConfig{}),
+		},
+	})
+	a.NilError(err)
+	a.Equal(unknown, []string{
+		"log.log_levle",
+		"server.adr",
+		"server.backends.weight",
+		"unused.foo",
+	})
+}
+
+// testConfigNode 是一个递归引用自身的配置。
+type testConfigNode struct {
+	Name     string            `config:"name"`
+	Password string            `config:"password" secret:"true"`
+	Children []*testConfigNode `config:"children"`
+}
+
+func TestUnknownConfigKeysRecursive(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "service.conf")
+	writeTestFile(a, confPath, `[tree]
+name = "root"
+nmae = "typo"
+
+[[tree.children]]
+name = "child"
+
+[[tree.children.children]]
+name = "grandchild"
+`)
+
+	c, err := config.LoadFile(confPath)
+	a.NilError(err)
+
+	unknown, err := unknownConfigKeys(c, []*configBinding{
+		{
+			Section: "tree",
+			Type:    reflect.TypeOf(&testConfigNode{}),
+		},
+	})
+	a.NilError(err)
+	a.Equal(unknown, []string{"tree.nmae"})
+}
+
+func TestStrictConfig(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[server]\naddr = \":8080\"\nadr = \":8081\"\n", filepath.Join(dir, "log", "test.log")))
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		*flagStrictConfig = false
+		configHandlers = nil
+		configBindings = nil
+		clientHandlers = nil
+	}()
+
+	configHandlers = nil
+	configBindings = nil
+	clientHandlers = nil
+	AddClient("server", func(ctx context.Context, c *testStrictConfig) {})
+	a.Equal(run(), ExitCodeOK)
+
+	*flagStrictConfig = true
+	a.Equal(run(), ExitCodeInvalidConfig)
+}
@@ -0,0 +1,245 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/altstory/go-log"
+)
+
+// PhaseTask 表示通过 Go 启动的后台任务。
+const PhaseTask Phase = "task"
+
+// TaskStatus 是后台任务的状态。
+type TaskStatus struct {
+	Name      string    `json:"name"`               // Name 是任务的名字。
+	Caller    string    `json:"caller"`             // Caller 是启动任务的调用方。
+	StartTime time.Time `json:"start_time"`         // StartTime 是任务开始执行的时间。
+	EndTime   time.Time `json:"end_time,omitempty"` // EndTime 是任务结束的时间，任务还在执行时为零值。
+	Running   bool      `json:"running"`            // Running 表示任务是否还在执行。
+	Err       string    `json:"err,omitempty"`      // Err 是任务返回的错误，任务 panic 也会被记录为错误。
+}
+
+// task 是一个通过 Go 启动的后台任务。
+type task struct {
+	Status *TaskStatus
+	Cancel context.CancelFunc
+}
+
+// taskRegistry 记录一次运行中启动的所有任务，每次运行结束之后都会换成新的 taskRegistry，
+// 上一次运行中没有及时结束的任务不会影响新的运行。
+type taskRegistry struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	stopping bool
+	running  map[*task]bool
+	finished map[string]*TaskStatus // finished 保存每个名字最后一次结束的任务。
+}
+
+var tasks struct {
+	mu       sync.Mutex
+	registry *taskRegistry
+}
+
+func currentTasks() *taskRegistry {
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+
+	if tasks.registry == nil {
+		tasks.registry = &taskRegistry{}
+	}
+
+	return tasks.registry
+}
+
+// Go 在后台 goroutine 中执行 fn，并由 runner 负责管理它的生命周期。
+//
+// fn 的 panic 会像其他 handler 一样被捕获并报告给 PanicHandler，返回的错误会记录在日志里。
+// 服务退出时，runner 会在执行 OnExit 注册的函数之前取消所有任务的 ctx，
+// 并等待它们返回，最长等待 [runner] 中 shutdown_timeout 设置的时间。
+//
+// 传给 fn 的 ctx 继承了 ctx 中的值，但不会因为 ctx 被取消而取消，只会在服务退出时取消。
+// 这样，即使在设置了超时的 OnStart 函数中启动任务，任务也不会在函数返回后被取消。
+//
+// 在服务启动之前，例如在 init 或者读取配置时启动的任务，同样会在服务退出时被取消和等待。
+// 服务开始退出之后再启动的任务会立即取消 ctx，runner 不会等待它们返回。
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	if fn == nil {
+		return
+	}
+
+	caller := findCaller(1)
+	info := &HandlerInfo{
+		Phase:  PhaseTask,
+		Caller: caller,
+	}
+	ctx, cancel := context.WithCancel(detachedContext{ctx})
+	t := &task{
+		Status: &TaskStatus{
+			Name:      name,
+			Caller:    caller,
+			StartTime: time.Now(),
+			Running:   true,
+		},
+		Cancel: cancel,
+	}
+	r := currentTasks()
+	r.mu.Lock()
+
+	if r.running == nil {
+		r.running = map[*task]bool{}
+	}
+
+	r.running[t] = true
+
+	// 服务已经开始退出，任务应该立即结束，并且不能再加入 stopTasks 正在等待的 wg。
+	tracked := !r.stopping
+
+	if tracked {
+		r.wg.Add(1)
+	} else {
+		cancel()
+	}
+
+	r.mu.Unlock()
+
+	go func() {
+		if tracked {
+			defer r.wg.Done()
+		}
+
+		defer cancel()
+
+		var err error
+		code := handler(func(ctx context.Context) int {
+			if err = fn(ctx); err != nil {
+				return ExitCodeHandlerError
+			}
+
+			return ExitCodeOK
+		}).CallWithInfo(ctx, info)
+
+		// fn 发生了 panic。
+		if code != ExitCodeOK && err == nil {
+			err = errors.New("go-runner: task panics")
+		}
+
+		if err != nil && err != context.Canceled {
+			log.Errorf(ctx, "name=%v||caller=%v||err=%v||go-runner: task fails", name, caller, err)
+		}
+
+		r.finish(t, err)
+	}()
+}
+
+func (r *taskRegistry) finish(t *task, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := *t.Status
+	status.EndTime = time.Now()
+	status.Running = false
+
+	if err != nil {
+		status.Err = err.Error()
+	}
+
+	if r.finished == nil {
+		r.finished = map[string]*TaskStatus{}
+	}
+
+	delete(r.running, t)
+	r.finished[status.Name] = &status
+}
+
+// Tasks 返回所有正在执行的任务，以及每个名字最后一次结束的任务，按照名字和开始时间排序。
+func Tasks() []*TaskStatus {
+	return currentTasks().list()
+}
+
+func (r *taskRegistry) list() []*TaskStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]*TaskStatus, 0, len(r.running)+len(r.finished))
+
+	for t := range r.running {
+		status := *t.Status
+		list = append(list, &status)
+	}
+
+	for _, s := range r.finished {
+		status := *s
+		list = append(list, &status)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+
+		return list[i].StartTime.Before(list[j].StartTime)
+	})
+	return list
+}
+
+// stopTasks 取消所有后台任务，并等待它们返回，最长等待 [runner] 中 shutdown_timeout 设置的时间。
+func stopTasks(ctx context.Context) {
+	r := currentTasks()
+	r.mu.Lock()
+	r.stopping = true
+
+	for t := range r.running {
+		t.Cancel()
+	}
+
+	r.mu.Unlock()
+
+	done := make(chan bool)
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	timeout := runnerFromContext(ctx).RunnerConfig.ShutdownTimeout
+	var expired <-chan time.Time
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-done:
+	case <-expired:
+		for _, status := range r.list() {
+			if status.Running {
+				log.Errorf(ctx, "name=%v||caller=%v||timeout=%v||go-runner: task does not stop in time", status.Name, status.Caller, timeout)
+			}
+		}
+	}
+}
+
+// resetTasks 在一次运行结束之后创建新的任务列表，上一次运行留下的任务不会再被等待。
+func resetTasks() {
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	tasks.registry = &taskRegistry{}
+}
+
+// detachedContext 保留 parent 中的值，但不会被 parent 取消。
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) { return }
+func (detachedContext) Done() <-chan struct{}                   { return nil }
+func (detachedContext) Err() error                              { return nil }
+
+func (ctx detachedContext) Value(key interface{}) interface{} {
+	return ctx.parent.Value(key)
+}
@@ -0,0 +1,157 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+func TestGo(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer func() {
+		resetTestInjection()
+		onExitHandlers = nil
+	}()
+	resetTestInjection()
+	onExitHandlers = nil
+
+	var running, exited []*TaskStatus
+	OnStart(func(ctx context.Context) error {
+		// 任务不会因为 OnStart 的 ctx 超时而取消。
+		Go(ctx, "worker", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		Go(ctx, "failed", func(ctx context.Context) error {
+			return errors.New("error")
+		})
+		Go(ctx, "panic", func(ctx context.Context) error {
+			panic("task")
+		})
+		return nil
+	}, HookTimeout(time.Second))
+	AddServer("", func(ctx context.Context) {
+		time.Sleep(20 * time.Millisecond)
+		running = Tasks()
+	})
+	OnExit(func(ctx context.Context) {
+		exited = Tasks()
+	})
+
+	a.Equal(run(), ExitCodeOK)
+
+	a.Equal(len(running), 3)
+	a.Equal(running[0].Name, "failed")
+	a.Equal(running[0].Err, "error")
+	a.Assert(!running[0].Running)
+	a.Equal(running[1].Name, "panic")
+	a.Assert(!running[1].Running)
+	a.Assert(running[1].Err != "")
+	a.Equal(running[2].Name, "worker")
+	a.Assert(running[2].Running)
+
+	// 退出函数执行之前，所有任务都已经结束。
+	a.Equal(len(exited), 3)
+	a.Equal(exited[2].Name, "worker")
+	a.Assert(!exited[2].Running)
+	a.Equal(exited[2].Err, context.Canceled.Error())
+}
+
+func TestGoShutdownTimeout(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[runner]\nshutdown_timeout = \"20ms\"\n", filepath.Join(dir, "log", "test.log")))
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		resetTestInjection()
+	}()
+	resetTestInjection()
+
+	block := make(chan bool)
+	stopped := make(chan bool)
+
+	OnStart(func(ctx context.Context) error {
+		Go(ctx, "stuck", func(ctx context.Context) error {
+			defer close(stopped)
+			<-block
+			return nil
+		})
+		return nil
+	})
+
+	// 任务没有及时结束时，服务不会一直等待。
+	start := time.Now()
+	a.Equal(run(), ExitCodeOK)
+	a.Assert(time.Since(start) < time.Second)
+
+	// 下一次运行使用新的任务列表，不会等待上一次运行留下的任务。
+	resetTestInjection()
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(len(Tasks()), 0)
+
+	close(block)
+	<-stopped
+}
+
+func TestGoOutsideRun(t *testing.T) {
+	a := assert.New(t)
+
+	cwd, err := os.Getwd()
+	a.NilError(err)
+	defer os.Chdir(cwd)
+	a.NilError(os.Chdir(path.Join(cwd, "internal", "testdata")))
+
+	defer func() {
+		resetTestInjection()
+		onExitHandlers = nil
+	}()
+	resetTestInjection()
+	onExitHandlers = nil
+
+	// 在服务启动之前启动的任务同样会在退出时被取消和等待。
+	Go(context.Background(), "early", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	var exited []*TaskStatus
+	lateErr := make(chan error, 1)
+	OnExit(func(ctx context.Context) {
+		exited = Tasks()
+
+		// 服务开始退出之后启动的任务会立即被取消。
+		Go(ctx, "late", func(ctx context.Context) error {
+			lateErr <- ctx.Err()
+			return nil
+		})
+	})
+
+	a.Equal(run(), ExitCodeOK)
+	a.Equal(len(exited), 1)
+	a.Equal(exited[0].Name, "early")
+	a.Assert(!exited[0].Running)
+	a.Equal(exited[0].Err, context.Canceled.Error())
+	a.Equal(<-lateErr, context.Canceled)
+}
@@ -0,0 +1,424 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const validateTagName = "validate"
+
+var (
+	typeOfDuration = reflect.TypeOf(time.Duration(0))
+	typeOfTime     = reflect.TypeOf(time.Time{})
+)
+
+// validator 是可以自我校验的配置，runner 会在反序列化配置之后调用 Validate 方法。
+type validator interface {
+	Validate() error
+}
+
+// validationError 是一个配置字段的校验错误。
+type validationError struct {
+	Path string // Path 是出错字段在配置中的完整路径，例如 http.server.addr。
+	Err  error
+}
+
+func (err *validationError) Error() string {
+	return fmt.Sprintf("%v: %v", err.Path, err.Err)
+}
+
+// validationErrors 是一组校验错误，校验配置时会收集所有的错误而不是遇到第一个错误就返回。
+type validationErrors []*validationError
+
+func (errs validationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return "go-runner: invalid config: " + strings.Join(msgs, "; ")
+}
+
+// validateConfig 根据字段上的 validate tag 和 Validate 方法校验 v，section 是 v 在配置中的路径。
+//
+// validate tag 的格式是用“,”分隔的规则列表，例如：
+//
+//     type ServerConfig struct {
+//         Addr     string        `config:"addr" validate:"required"`
+//         Mode     string        `config:"mode" validate:"oneof=debug release"`
+//         Workers  int           `config:"workers" validate:"min=1,max=64"`
+//         Timeout  time.Duration `config:"timeout" validate:"min=1s"`
+//         Upstream string        `config:"upstream" validate:"url"`
+//     }
+//
+// 支持的规则包括：
+//     - required：值不能为零值，对于 slice 和 map 来说长度不能为 0；
+//     - min=n、max=n：数值的大小范围，对于 string、slice 和 map 来说是长度范围，
+//                     对于 time.Duration 来说 n 可以写成 1s 这样的时长；
+//     - oneof=a b c：值必须是用空格分隔的选项之一；
+//     - duration：字符串必须是合法的 time.Duration 格式；
+//     - url：字符串必须是包含 scheme 的合法 URL。
+//
+// 除了 required 以外，其他规则都会忽略空字符串和 nil，是否必填应该由 required 决定。
+// 如果 v 是 nil，即配置中不存在这个 section，那么会校验设置了默认值的零值，
+// 这样 section 中的 required 字段依然会报错。
+func validateConfig(section string, v interface{}) error {
+	var errs validationErrors
+	rv, err := sectionValue(section, reflect.ValueOf(v))
+
+	if err != nil {
+		return err
+	}
+
+	validateValue(&errs, section, rv)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// sectionValue 返回 v 指向的值，如果 v 是 nil，则返回一个设置了默认值的零值。
+func sectionValue(section string, v reflect.Value) (reflect.Value, error) {
+	missing := false
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.New(v.Type().Elem())
+			missing = true
+		}
+
+		v = v.Elem()
+	}
+
+	if missing {
+		if err := applyDefaults(section, v, reflect.Value{}); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+
+	return v, nil
+}
+
+func validateValue(errs *validationErrors, path string, v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		validateStruct(errs, path, v)
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(errs, fmt.Sprintf("%v[%v]", path, i), v.Index(i))
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+
+		for _, key := range keys {
+			validateValue(errs, joinConfigPath(path, fmt.Sprint(key.Interface())), v.MapIndex(key))
+		}
+	}
+}
+
+func validateStruct(errs *validationErrors, path string, v reflect.Value) {
+	t := v.Type()
+
+	if t == typeOfTime {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// 跳过私有字段，go-config 也不会给这些字段赋值。
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, squash, skipped := parseConfigTag(field)
+
+		if skipped {
+			continue
+		}
+
+		fieldPath := path
+
+		if !squash {
+			fieldPath = joinConfigPath(path, name)
+		}
+
+		fv := v.Field(i)
+
+		for _, rule := range strings.Split(field.Tag.Get(validateTagName), ",") {
+			rule = strings.TrimSpace(rule)
+
+			if rule == "" {
+				continue
+			}
+
+			if err := checkRule(rule, fv); err != nil {
+				*errs = append(*errs, &validationError{
+					Path: fieldPath,
+					Err:  err,
+				})
+			}
+		}
+
+		validateValue(errs, fieldPath, fv)
+	}
+
+	var val validator
+
+	if v.CanAddr() {
+		val, _ = v.Addr().Interface().(validator)
+	} else {
+		val, _ = v.Interface().(validator)
+	}
+
+	if val == nil {
+		return
+	}
+
+	if err := val.Validate(); err != nil {
+		*errs = append(*errs, &validationError{
+			Path: path,
+			Err:  err,
+		})
+	}
+}
+
+// parseConfigTag 解析字段上的 config tag，规则与 go-config 保持一致。
+func parseConfigTag(field reflect.StructField) (name string, squash, skipped bool) {
+	opts := strings.Split(field.Tag.Get(configTagName), ",")
+	name = strings.TrimSpace(opts[0])
+
+	if name == "-" {
+		skipped = true
+		return
+	}
+
+	for _, opt := range opts[1:] {
+		if opt == "squash" {
+			squash = true
+		}
+	}
+
+	if name == "" {
+		name = field.Name
+	}
+
+	return
+}
+
+func joinConfigPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// checkRule 检查 v 是否满足 rule 规则。
+func checkRule(rule string, v reflect.Value) error {
+	name, param := rule, ""
+
+	if idx := strings.Index(rule, "="); idx >= 0 {
+		name, param = rule[:idx], rule[idx+1:]
+	}
+
+	if name == "required" {
+		if isEmptyValue(v) {
+			return errors.New("value is required")
+		}
+
+		return nil
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	switch name {
+	case "min", "max":
+		return checkRange(name, param, v)
+
+	case "oneof":
+		if v.Kind() == reflect.String && v.Len() == 0 {
+			return nil
+		}
+
+		value := fmt.Sprint(v.Interface())
+
+		for _, opt := range strings.Fields(param) {
+			if value == opt {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("value %v must be one of [%v]", value, param)
+
+	case "duration":
+		if v.Type() == typeOfDuration {
+			return nil
+		}
+
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("rule duration cannot be applied to type %v", v.Type())
+		}
+
+		if v.Len() == 0 {
+			return nil
+		}
+
+		if _, err := time.ParseDuration(v.String()); err != nil {
+			return fmt.Errorf("value %v is not a valid duration", v.String())
+		}
+
+		return nil
+
+	case "url":
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("rule url cannot be applied to type %v", v.Type())
+		}
+
+		if v.Len() == 0 {
+			return nil
+		}
+
+		if u, err := url.Parse(v.String()); err != nil || u.Scheme == "" || (u.Host == "" && u.Path == "") {
+			return fmt.Errorf("value %v is not a valid url", v.String())
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown validation rule %v", name)
+}
+
+func checkRange(name, param string, v reflect.Value) error {
+	var cmp int
+	var actual interface{}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var bound int64
+		var err error
+
+		if v.Type() == typeOfDuration {
+			var d time.Duration
+			d, err = time.ParseDuration(param)
+			bound = int64(d)
+			actual = time.Duration(v.Int())
+		} else {
+			bound, err = strconv.ParseInt(param, 10, 64)
+			actual = v.Int()
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid %v rule parameter %v", name, param)
+		}
+
+		switch {
+		case v.Int() < bound:
+			cmp = -1
+		case v.Int() > bound:
+			cmp = 1
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		bound, err := strconv.ParseUint(param, 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid %v rule parameter %v", name, param)
+		}
+
+		actual = v.Uint()
+
+		switch {
+		case v.Uint() < bound:
+			cmp = -1
+		case v.Uint() > bound:
+			cmp = 1
+		}
+
+	case reflect.Float32, reflect.Float64:
+		bound, err := strconv.ParseFloat(param, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid %v rule parameter %v", name, param)
+		}
+
+		actual = v.Float()
+
+		switch {
+		case v.Float() < bound:
+			cmp = -1
+		case v.Float() > bound:
+			cmp = 1
+		}
+
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		bound, err := strconv.ParseInt(param, 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid %v rule parameter %v", name, param)
+		}
+
+		if name == "min" && int64(v.Len()) < bound {
+			return fmt.Errorf("length %v is less than %v", v.Len(), bound)
+		}
+
+		if name == "max" && int64(v.Len()) > bound {
+			return fmt.Errorf("length %v is greater than %v", v.Len(), bound)
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("rule %v cannot be applied to type %v", name, v.Type())
+	}
+
+	if name == "min" && cmp < 0 {
+		return fmt.Errorf("value %v is less than %v", actual, param)
+	}
+
+	if name == "max" && cmp > 0 {
+		return fmt.Errorf("value %v is greater than %v", actual, param)
+	}
+
+	return nil
+}
+
+// isEmptyValue 判断 v 是否是零值，对于 slice 和 map 来说长度为 0 也是零值。
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/huandu/go-assert"
+)
+
+type testValidateUpstream struct {
+	Name string `config:"name" validate:"required"`
+}
+
+type testValidateConfig struct {
+	Addr      string                 `config:"addr" validate:"required"`
+	Mode      string                 `config:"mode" validate:"oneof=debug release"`
+	Workers   int                    `config:"workers" validate:"min=1,max=64"`
+	Timeout   time.Duration          `config:"timeout" validate:"min=1s"`
+	Interval  string                 `config:"interval" validate:"duration"`
+	Endpoint  string                 `config:"endpoint" validate:"url"`
+	Tags      []string               `config:"tags" validate:"max=2"`
+	Upstreams []testValidateUpstream `config:"upstreams"`
+}
+
+func (c *testValidateConfig) Validate() error {
+	if c.Mode == "debug" && c.Workers > 1 {
+		return errors.New("debug mode allows only 1 worker")
+	}
+
+	return nil
+}
+
+func TestValidateConfig(t *testing.T) {
+	a := assert.New(t)
+
+	valid := &testValidateConfig{
+		Addr:      ":8080",
+		Mode:      "release",
+		Workers:   4,
+		Timeout:   time.Second,
+		Interval:  "1m",
+		Endpoint:  "http://localhost:8080/api",
+		Tags:      []string{"a", "b"},
+		Upstreams: []testValidateUpstream{{Name: "foo"}},
+	}
+	a.NilError(validateConfig("server", valid))
+
+	// section 不存在时，required 字段依然会报错。
+	err := validateConfig("server", (*testValidateConfig)(nil))
+	a.NonNilError(err)
+	a.Equal(err.(validationErrors)[0].Path, "server.addr")
+	a.NilError(validateConfig("foo", (**fooConfig)(nil)))
+
+	invalid := &testValidateConfig{
+		Mode:      "debug",
+		Workers:   100,
+		Timeout:   time.Millisecond,
+		Interval:  "abc",
+		Endpoint:  "localhost:8080",
+		Tags:      []string{"a", "b", "c"},
+		Upstreams: []testValidateUpstream{{Name: "foo"}, {}},
+	}
+	err = validateConfig("server", invalid)
+	a.NonNilError(err)
+
+	var paths []string
+
+	for _, e := range err.(validationErrors) {
+		paths = append(paths, e.Path)
+	}
+
+	a.Equal(paths, []string{
+		"server.addr",
+		"server.workers",
+		"server.timeout",
+		"server.interval",
+		"server.endpoint",
+		"server.tags",
+		"server.upstreams[1].name",
+		"server",
+	})
+}
+
+type testValidateUnknownRule struct {
+	Foo string `config:"foo" validate:"not_a_rule"`
+}
+
+func TestValidateUnknownRule(t *testing.T) {
+	a := assert.New(t)
+	a.NonNilError(validateConfig("", &testValidateUnknownRule{}))
+}
+
+func TestCheckConfigs(t *testing.T) {
+	a := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "go-runner")
+	a.NilError(err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "conf", "service.conf")
+	writeTestFile(a, confPath, fmt.Sprintf("[log]\nlog_path = %q\n\n[server]\nmode = \"release\"\nworkers = 0\n", filepath.Join(dir, "log", "test.log")))
+
+	old := *flagConfig
+	*flagConfig = confPath
+	defer func() {
+		*flagConfig = old
+		configHandlers = nil
+		configBindings = nil
+		clientHandlers = nil
+	}()
+
+	called := false
+	configHandlers = nil
+	configBindings = nil
+	clientHandlers = nil
+	AddClient("", func(ctx context.Context) {
+		called = true
+	})
+	AddClient("server", func(ctx context.Context, c *testValidateConfig) {})
+	a.Equal(run(), ExitCodeInvalidConfig)
+	a.Assert(!called)
+
+	// 整个 section 不存在时，required 字段依然会报错。
+	var loaded *testValidateConfig
+	configHandlers = nil
+	configBindings = nil
+	clientHandlers = nil
+	LoadConfig("not_exist", &loaded)
+	a.Equal(run(), ExitCodeInvalidConfig)
+
+	// 没有 required 字段的 section 可以不存在。
+	var optional *fooConfig
+	configHandlers = nil
+	configBindings = nil
+	clientHandlers = nil
+	LoadConfig("not_exist", &optional)
+	a.Equal(run(), ExitCodeOK)
+	a.Assert(optional == nil)
+}